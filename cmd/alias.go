@@ -0,0 +1,248 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// aliasCmd is the parent of the alias subcommands
+var aliasCmd = &cobra.Command{
+	Use:   "alias",
+	Short: "Save and restore named sets of active versions",
+	Long: `
+Save the currently active versions of all binaries under a name, then later
+use them all again at once; this is handy for switching between projects which
+each need a particular combination of binary versions.
+
+Aliases are stored in aliases.yaml alongside the hvm configuration file.
+`,
+	Example: `
+  hvm alias set prod-stack
+
+  hvm alias use prod-stack
+
+  hvm alias list
+
+  hvm alias rm prod-stack`,
+}
+
+// aliasSetCmd saves the currently active versions under a name
+var aliasSetCmd = &cobra.Command{
+	Use:   "set (<name>)",
+	Short: "Save the currently active versions under a name",
+	Args:  aliasNameArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		v, aliasFile, err := readAliases()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), err)
+			os.Exit(1)
+		}
+		versions := map[string]string{}
+		for _, b := range hvm.SupportedBinaries() {
+			active, err := SymlinkedVersion(b)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine active %s version with error: %v", b, err))
+				os.Exit(1)
+			}
+			if active != "" {
+				versions[b] = active
+			}
+		}
+		if len(versions) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "Cannot set alias; no binary versions are currently in use.")
+			os.Exit(1)
+		}
+		v.Set(name, versions)
+		if err := v.WriteConfigAs(aliasFile); err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot write alias file %s with error: %v", aliasFile, err))
+			os.Exit(1)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Set alias %s to %s", name, formatAlias(versions)))
+	},
+}
+
+// aliasUseCmd uses every version saved under a name
+var aliasUseCmd = &cobra.Command{
+	Use:   "use (<name>)",
+	Short: "Use every version saved under a name",
+	Args:  aliasNameArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		v, _, err := readAliases()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), err)
+			os.Exit(1)
+		}
+		if !v.IsSet(name) {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Alias %s does not exist.", name))
+			os.Exit(1)
+		}
+		userHome, err := userHomeDir()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("cannot access home directory with error: %v", err))
+			os.Exit(1)
+		}
+		hvmHome := HvmDataDir(userHome)
+		versions := v.GetStringMapString(name)
+		binaries := make([]string, 0, len(versions))
+		for b := range versions {
+			binaries = append(binaries, b)
+		}
+		sort.Strings(binaries)
+		// An alias is used either completely or not at all
+		if err := useSet(userHome, hvmHome, versions); err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), err)
+			os.Exit(1)
+		}
+		for _, b := range binaries {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Using %s version %s", b, versions[b]))
+		}
+	},
+}
+
+// aliasListCmd prints every alias and its versions
+var aliasListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all aliases and their versions",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		v, _, err := readAliases()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), err)
+			os.Exit(1)
+		}
+		names := v.AllSettings()
+		if len(names) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No aliases are set.")
+			return
+		}
+		keys := make([]string, 0, len(names))
+		for k := range names {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		aliases := []string{}
+		for _, k := range keys {
+			aliases = append(aliases, fmt.Sprintf("%s: | %s", k, formatAlias(v.GetStringMapString(k))))
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), columnize.SimpleFormat(aliases))
+	},
+}
+
+// aliasRmCmd removes an alias
+var aliasRmCmd = &cobra.Command{
+	Use:   "rm (<name>)",
+	Short: "Remove an alias",
+	Args:  aliasNameArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		v, aliasFile, err := readAliases()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), err)
+			os.Exit(1)
+		}
+		if !v.IsSet(name) {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Alias %s does not exist.", name))
+			os.Exit(1)
+		}
+		// viper cannot unset a key, so write what remains into a fresh instance
+		remaining := viper.New()
+		for k, val := range v.AllSettings() {
+			if k != name {
+				remaining.Set(k, val)
+			}
+		}
+		if err := remaining.WriteConfigAs(aliasFile); err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot write alias file %s with error: %v", aliasFile, err))
+			os.Exit(1)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Removed alias %s", name))
+	},
+}
+
+// Initialize the command
+func init() {
+	rootCmd.AddCommand(aliasCmd)
+	aliasCmd.AddCommand(aliasSetCmd)
+	aliasCmd.AddCommand(aliasUseCmd)
+	aliasCmd.AddCommand(aliasListCmd)
+	aliasCmd.AddCommand(aliasRmCmd)
+}
+
+// aliasNameArgs requires exactly one argument which can be used as an alias name
+func aliasNameArgs(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("requires exactly one argument, the name of an alias.")
+	}
+	// viper treats dots as nested keys and lower cases everything
+	if strings.Contains(args[0], ".") || strings.ToLower(args[0]) != args[0] {
+		return fmt.Errorf("Cannot use %q as an alias name; use lower case names without dots", args[0])
+	}
+	return nil
+}
+
+// readAliases reads the alias file into its own viper instance and returns it
+// along with the path of the file
+func readAliases() (*viper.Viper, string, error) {
+	userHome, err := userHomeDir()
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot access home directory with error: %v", err)
+	}
+	configDir := HvmConfigDir(userHome)
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return nil, "", fmt.Errorf("Cannot create directory %s with error: %v", configDir, err)
+	}
+	aliasFile := fmt.Sprintf("%s/aliases.yaml", configDir)
+	v := viper.New()
+	v.SetConfigFile(aliasFile)
+	if _, err := os.Stat(aliasFile); err == nil {
+		if err := v.ReadInConfig(); err != nil {
+			return nil, "", fmt.Errorf("Cannot read alias file %s with error: %v", aliasFile, err)
+		}
+	}
+	return v, aliasFile, nil
+}
+
+// formatAlias returns the versions of an alias as a sorted binary@version list
+func formatAlias(versions map[string]string) string {
+	pairs := []string{}
+	for b, ver := range versions {
+		pairs = append(pairs, fmt.Sprintf("%s@%s", b, ver))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
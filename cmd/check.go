@@ -0,0 +1,107 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+)
+
+// checkCmd verifies the tool versions declared by a project file are in use
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the tool versions declared by the project are installed and in use",
+	Long: `
+Check that every tool version declared under tools in the hvm.yaml project
+file in the current directory, or the closest parent directory with one, is
+installed and in use, exiting non-zero if any is not; run hvm install without
+a binary to install and use them all. The .terraform-version file of tfenv and
+the .tool-versions file of asdf are read too.
+`,
+	Example: `
+  hvm check`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cwd, err := os.Getwd()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine current directory with error: %v", err))
+			os.Exit(1)
+		}
+		p, _, err := FindProject(cwd)
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), err)
+			os.Exit(exitCode(err))
+		}
+		if p == nil || len(p.Tools) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot check; no %s, %s or %s file declaring tools found in %s or its parent directories.", ProjectFileName, TerraformVersionFileName, ToolVersionsFileName, cwd))
+			os.Exit(1)
+		}
+		ok := true
+		out := []string{"Binary | Required | Active | Status"}
+		for _, b := range p.Binaries() {
+			v := p.Tools[b]
+			status := "ok"
+			installed, err := hvm.IsInstalledVersion(b, v)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine if %s version %s is installed: %v", b, v, err))
+				os.Exit(1)
+			}
+			active, err := ActiveVersion(b, cmd.ErrOrStderr())
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine the %s version in use: %v", b, err))
+				os.Exit(1)
+			}
+			switch {
+			case !installed:
+				status = "not installed"
+			case active != v:
+				status = "not in use"
+			}
+			if status != "ok" {
+				ok = false
+			}
+			if active == "" {
+				active = "none"
+			}
+			out = append(out, fmt.Sprintf("%s | %s | %s | %s", b, v, active, status))
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), columnize.SimpleFormat(out))
+		if !ok {
+			fmt.Fprintln(cmd.OutOrStdout(), "")
+			fmt.Fprintln(cmd.OutOrStdout(), "Run hvm install to install and use the declared versions.")
+			os.Exit(hvm.ExitFailure)
+		}
+	},
+}
+
+// Initialize the command
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
@@ -0,0 +1,163 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+	"github.com/spf13/cobra"
+)
+
+// CleanMeta contains data for cleaning the hvm data directory
+type CleanMeta struct {
+	All      bool
+	Yes      bool
+	LogFile  string
+	UserHome string
+	HvmHome  string
+}
+
+var (
+	cleanAll bool
+	cleanYes bool
+)
+
+// cleanCmd removes the log and caches while keeping installed binaries
+var cleanCmd = &cobra.Command{
+	Use:   "clean [--all]",
+	Short: "Remove the log and caches without touching installed binaries",
+	Long: `
+Remove the hvm log file along with its rotated files and the cache directory
+holding version lists and partial downloads, leaving installed binary versions
+in place; this gives a fresh start on metadata without reinstalling anything.
+
+With --all, everything hvm keeps in its data directory is removed as well,
+including every installed binary version, the locks, kept archives and state,
+along with the links in ~/bin and global_bin_dir which point into it. Configuration files such as
+hvm.yaml and anything else hvm did not create are kept, and the directory itself
+is removed only once it is empty. As this cannot be undone, --all asks for
+confirmation first unless --yes is given.
+`,
+	Example: `
+  hvm clean
+
+  hvm clean --all
+
+  hvm clean --all --yes`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		m := CleanMeta{}
+		userHome, err := userHomeDir()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot access home directory with error: %v", err))
+			os.Exit(1)
+		}
+		m.UserHome = userHome
+		m.HvmHome = HvmDataDir(m.UserHome)
+		m.LogFile = LogFilePath(m.HvmHome)
+		m.All = cleanAll
+		m.Yes = cleanYes
+		if m.All && !m.Yes {
+			if !isTerminal(os.Stdin) {
+				fmt.Fprintln(cmd.OutOrStdout(), "Cannot clean --all without confirmation; give --yes to remove every installed binary version.")
+				os.Exit(1)
+			}
+			if !confirm(cmd.InOrStdin(), cmd.OutOrStdout(), fmt.Sprintf("Remove every installed binary version and the rest of the hvm data in %s?", m.HvmHome)) {
+				fmt.Fprintln(cmd.OutOrStdout(), "Nothing was cleaned.")
+				return
+			}
+		}
+		paths := []string{m.LogFile}
+		rotated, err := filepath.Glob(fmt.Sprintf("%s.[0-9]*", m.LogFile))
+		if err == nil {
+			paths = append(paths, rotated...)
+		}
+		paths = append(paths, fmt.Sprintf("%s/cache", m.HvmHome))
+		if m.All {
+			// Links left pointing into the removed versions would dangle, so
+			// every one of them is found before anything is removed, and they
+			// go first so a link which cannot be removed leaves the versions
+			links, err := ManagedLinks(m.UserHome, m.HvmHome)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot find the links into %s with error: %v", m.HvmHome, err))
+				os.Exit(1)
+			}
+			linkPaths := []string{}
+			for _, l := range links {
+				linkPaths = append(linkPaths, l.Path)
+			}
+			paths = append(linkPaths, paths...)
+			// Only what hvm creates is removed, since --home or HVM_HOME may
+			// point at a directory which also holds anything else
+			for _, b := range hvm.SupportedBinaries() {
+				paths = append(paths, fmt.Sprintf("%s/%s", m.HvmHome, b))
+			}
+			paths = append(paths,
+				fmt.Sprintf("%s/locks", m.HvmHome),
+				hvm.ArchivesDir(m.HvmHome),
+				statePath(m.HvmHome))
+		}
+		removed := 0
+		for _, path := range paths {
+			if _, err := os.Lstat(path); os.IsNotExist(err) {
+				continue
+			}
+			if err := os.RemoveAll(path); err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot remove %s with error: %v", path, err))
+				os.Exit(1)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Removed %s", path))
+			removed++
+		}
+		if m.All {
+			// Removed only when nothing else, such as hvm.yaml, is left in it
+			if err := os.Remove(m.HvmHome); err == nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Removed %s", m.HvmHome))
+				removed++
+			}
+		}
+		if removed == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "Nothing to clean.")
+		}
+	},
+}
+
+// Initialize the command
+func init() {
+	rootCmd.AddCommand(cleanCmd)
+	cleanCmd.PersistentFlags().BoolVar(&cleanAll,
+		"all",
+		false,
+		"also remove every installed binary version and the rest of the hvm data")
+	cleanCmd.PersistentFlags().BoolVarP(&cleanYes,
+		"yes",
+		"y",
+		false,
+		"remove everything with --all without asking for confirmation")
+}
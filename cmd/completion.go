@@ -0,0 +1,196 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+	"github.com/spf13/cobra"
+)
+
+// CompletionMeta has the shell completion install options
+type CompletionMeta struct {
+	Shell    string
+	UserHome string
+}
+
+var completionShell string
+
+// completionShells are the shells completion scripts can be generated for
+var completionShells = []string{"bash", "fish", "powershell", "zsh"}
+
+// completionCmd prints a completion script; it replaces the default cobra
+// completion command so that it can have the install subcommand
+var completionCmd = &cobra.Command{
+	Use:     "completion [bash|fish|powershell|zsh]",
+	Aliases: []string{"completions"},
+	Short:   "Generate the autocompletion script for the specified shell",
+	Long: `
+Generate the autocompletion script for hvm for the specified shell and print
+it, or use the install subcommand to write it where the shell will find it.
+`,
+	Example: `
+  hvm completion zsh > ~/.zsh/completions/_hvm
+
+  hvm completion install`,
+	ValidArgs: completionShells,
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := genCompletion(cmd.Root(), args[0], cmd.OutOrStdout()); err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot generate %s completion with error: %v", args[0], err))
+			os.Exit(1)
+		}
+	},
+}
+
+// completionInstallCmd writes the completion script for the user's shell to
+// the location that shell loads completions from
+var completionInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the autocompletion script for your shell",
+	Long: `
+Install the autocompletion script for the shell named by $SHELL, or the one
+given with --shell, in the conventional location for that shell:
+
+  bash  $XDG_DATA_HOME/bash-completion/completions/hvm
+  fish  $XDG_CONFIG_HOME/fish/completions/hvm.fish
+  zsh   $HOME/.zsh/completions/_hvm
+
+Any line which must be added to the shell's startup file for the completions
+to be loaded is printed afterwards.
+`,
+	Example: `
+  hvm completion install
+
+  hvm completion install --shell zsh`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		m := CompletionMeta{}
+		userHome, err := userHomeDir()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("cannot access home directory with error: %v", err))
+			os.Exit(1)
+		}
+		m.UserHome = userHome
+		m.Shell = completionShell
+		if m.Shell == "" {
+			m.Shell = filepath.Base(os.Getenv("SHELL"))
+		}
+		if m.Shell == "" || m.Shell == "." {
+			fmt.Fprintln(cmd.OutOrStdout(), "Cannot determine your shell from $SHELL; give it with --shell.")
+			os.Exit(1)
+		}
+		completionFile, err := completionPath(m.UserHome, m.Shell)
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot install completion with error: %v", err))
+			os.Exit(hvm.ExitUnsupported)
+		}
+		var script bytes.Buffer
+		if err := genCompletion(cmd.Root(), m.Shell, &script); err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot generate %s completion with error: %v", m.Shell, err))
+			os.Exit(1)
+		}
+		if err := os.MkdirAll(filepath.Dir(completionFile), 0755); err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot create directory %s with error: %v", filepath.Dir(completionFile), err))
+			os.Exit(1)
+		}
+		if err := os.WriteFile(completionFile, script.Bytes(), 0644); err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot write completion file %s with error: %v", completionFile, err))
+			os.Exit(1)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Installed %s completion in %s", m.Shell, completionFile))
+		if hint := completionHint(m.Shell, completionFile); hint != "" {
+			fmt.Fprintln(cmd.OutOrStdout(), hint)
+		}
+	},
+}
+
+// Initialize the command
+func init() {
+	rootCmd.AddCommand(completionCmd)
+	completionCmd.AddCommand(completionInstallCmd)
+	completionInstallCmd.Flags().StringVar(&completionShell,
+		"shell",
+		"",
+		"shell to install completion for instead of the one named by $SHELL (bash, fish or zsh)")
+}
+
+// genCompletion writes the completion script of root for shell to w
+func genCompletion(root *cobra.Command, shell string, w io.Writer) error {
+	switch shell {
+	case "bash":
+		return root.GenBashCompletionV2(w, true)
+	case "fish":
+		return root.GenFishCompletion(w, true)
+	case "powershell":
+		return root.GenPowerShellCompletionWithDesc(w)
+	case "zsh":
+		return root.GenZshCompletion(w)
+	}
+	return fmt.Errorf("unsupported shell %q", shell)
+}
+
+// completionPath returns the file the completion script for shell is installed
+// in; powershell has no conventional location, so it is not supported
+func completionPath(userHome string, shell string) (string, error) {
+	switch shell {
+	case "bash":
+		dataHome := os.Getenv("XDG_DATA_HOME")
+		if dataHome == "" {
+			dataHome = filepath.Join(userHome, ".local", "share")
+		}
+		return filepath.Join(dataHome, "bash-completion", "completions", "hvm"), nil
+	case "fish":
+		configHome := os.Getenv("XDG_CONFIG_HOME")
+		if configHome == "" {
+			configHome = filepath.Join(userHome, ".config")
+		}
+		return filepath.Join(configHome, "fish", "completions", "hvm.fish"), nil
+	case "zsh":
+		return filepath.Join(userHome, ".zsh", "completions", "_hvm"), nil
+	case "powershell", "pwsh":
+		return "", errors.New("powershell has no conventional completion location; add the output of hvm completion powershell to your profile instead")
+	}
+	return "", fmt.Errorf("unsupported shell %q; give one of bash, fish or zsh with --shell", shell)
+}
+
+// completionHint returns what must be added to the startup file of shell for it
+// to load completionFile, or an empty string when it is loaded automatically
+func completionHint(shell string, completionFile string) string {
+	switch shell {
+	case "bash":
+		return fmt.Sprintf("The bash-completion package loads it in new shells; without bash-completion, add this line to ~/.bashrc:\n\n  source %s", completionFile)
+	case "zsh":
+		return fmt.Sprintf("Add these lines to ~/.zshrc, before any existing compinit, then start a new shell:\n\n  fpath=(%s $fpath)\n  autoload -U compinit && compinit", filepath.Dir(completionFile))
+	}
+	return ""
+}
@@ -0,0 +1,170 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// configCmd is the parent of the config subcommands
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "View and change hvm settings",
+	Long: `
+View and change the settings hvm reads from its configuration file,
+which is $XDG_CONFIG_HOME/hvm/hvm.yaml or $HOME/.hvm/hvm.yaml unless another
+file is given with --config.
+`,
+	Example: `
+  hvm config list
+
+  hvm config get cache_ttl
+
+  hvm config set prune_keep 5`,
+}
+
+// configGetCmd prints the value of a single setting
+var configGetCmd = &cobra.Command{
+	Use:   "get (<key>)",
+	Short: "Print the value of a setting",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("requires exactly one argument, the name of a setting.")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if !viper.IsSet(args[0]) {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Setting %s is not set.", args[0]))
+			os.Exit(1)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), displayValue(args[0], viper.Get(args[0])))
+	},
+}
+
+// configSetCmd changes the value of a single setting in the configuration file
+var configSetCmd = &cobra.Command{
+	Use:   "set (<key>) (<value>)",
+	Short: "Change the value of a setting",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 2 {
+			return errors.New("requires exactly two arguments, the name and value of a setting.")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		configFile, err := configFilePath()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine configuration file with error: %v", err))
+			os.Exit(1)
+		}
+		// Only the settings already in the file plus the new one are written,
+		// rather than every default viper knows about
+		v := viper.New()
+		v.SetConfigFile(configFile)
+		if _, err := os.Stat(configFile); err == nil {
+			if err := v.ReadInConfig(); err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot read configuration file %s with error: %v", configFile, err))
+				os.Exit(1)
+			}
+		}
+		v.Set(args[0], args[1])
+		if err := v.WriteConfigAs(configFile); err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot write configuration file %s with error: %v", configFile, err))
+			os.Exit(1)
+		}
+		viper.Set(args[0], args[1])
+		fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Set %s to %v in %s", args[0], displayValue(args[0], args[1]), configFile))
+	},
+}
+
+// configListCmd prints every setting and its value
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all settings and their values",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		keys := viper.AllKeys()
+		sort.Strings(keys)
+		settings := []string{}
+		for _, k := range keys {
+			settings = append(settings, fmt.Sprintf("%s: | %v", k, displayValue(k, viper.Get(k))))
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), columnize.SimpleFormat(settings))
+	},
+}
+
+// Initialize the command
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configListCmd)
+}
+
+// configFilePath returns the configuration file in use, or the default
+// location when no configuration file exists yet
+func configFilePath() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	if viper.ConfigFileUsed() != "" {
+		return viper.ConfigFileUsed(), nil
+	}
+	userHome, err := userHomeDir()
+	if err != nil {
+		return "", err
+	}
+	configDir := HvmConfigDir(userHome)
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/hvm.yaml", configDir), nil
+}
+
+// secretSettings are the settings which hold credentials for a mirror, so
+// that their values are never printed
+var secretSettings = []string{"mirror_password", "mirror_token", "mirror_headers"}
+
+// displayValue returns the value of setting key as it is printed, which is
+// redacted for a setting in secretSettings or any key within one
+func displayValue(key string, value interface{}) interface{} {
+	key = strings.ToLower(key)
+	for _, s := range secretSettings {
+		if key == s || strings.HasPrefix(key, s+".") {
+			return "<redacted>"
+		}
+	}
+	return value
+}
@@ -0,0 +1,121 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+)
+
+// DuMeta contains data for reporting disk usage
+type DuMeta struct {
+	BinaryNames []string
+	LogFile     string
+	UserHome    string
+	HvmHome     string
+}
+
+// duCmd reports disk usage of installed binary versions
+var duCmd = &cobra.Command{
+	Use:   "du [<binary>...]",
+	Short: "Report disk usage of installed binary versions",
+	Long: `
+Report the total disk space consumed by the installed versions of the
+specified binaries, or of every supported binary if none are specified,
+along with a grand total.
+`,
+	Example: `
+  hvm du
+
+  hvm du terraform vault`,
+	ValidArgs: hvm.SupportedBinaries(),
+	Args: func(cmd *cobra.Command, args []string) error {
+		for _, b := range args {
+			if !hvm.IsSupported(b) {
+				return unsupportedError(fmt.Errorf("Cannot report disk usage of %q; it is not a supported binary; for a list of supported binaries, use hvm du --help", b))
+			}
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		m := DuMeta{}
+		userHome, err := userHomeDir()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot access home directory with error: %v", err))
+			os.Exit(1)
+		}
+		m.UserHome = userHome
+		m.HvmHome = HvmDataDir(m.UserHome)
+		m.LogFile = LogFilePath(m.HvmHome)
+		m.BinaryNames = args
+		if len(m.BinaryNames) == 0 {
+			m.BinaryNames = hvm.SupportedBinaries()
+		}
+		if _, err := os.Stat(m.HvmHome); os.IsNotExist(err) {
+			err = CreateHvmHome(m.HvmHome)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				os.Exit(1)
+			}
+		}
+		f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot open log file %s with error: %v", m.LogFile, err))
+			os.Exit(1)
+		}
+		defer f.Close()
+		logger := newLogger(f)
+
+		var total int64
+		du := []string{}
+		for _, b := range m.BinaryNames {
+			size, err := DiskUsage(fmt.Sprintf("%s/%s", m.HvmHome, b))
+			if err != nil {
+				logger.Error("du", "binary", b, "error", err.Error())
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine disk usage for %s with error: %v", b, err))
+				os.Exit(1)
+			}
+			if size == 0 {
+				continue
+			}
+			total += size
+			du = append(du, fmt.Sprintf("%s: | %s", b, humanBytes(size)))
+		}
+		du = append(du, fmt.Sprintf("Total: | %s", humanBytes(total)))
+		fmt.Fprintln(cmd.OutOrStdout(), "Disk Usage")
+		fmt.Fprintln(cmd.OutOrStdout(), "")
+		fmt.Fprintln(cmd.OutOrStdout(), columnize.SimpleFormat(du))
+	},
+}
+
+// Initialize the command
+func init() {
+	rootCmd.AddCommand(duCmd)
+}
@@ -0,0 +1,139 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+	"github.com/spf13/cobra"
+)
+
+// EnvMeta has the environment options
+type EnvMeta struct {
+	Shell    string
+	UserHome string
+	HvmHome  string
+}
+
+var envShell string
+
+// envCmd prints the shell commands which set up the environment for hvm
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Print shell commands which put hvm managed binaries on PATH",
+	Long: `
+Print the commands for the shell named by $SHELL, or the one given with
+--shell, which put the directory hvm links binaries into at the front of PATH
+when it is not already on PATH, and which export HVM_HOME when the hvm home
+directory was given with --home.
+
+Evaluate the output in a shell profile, a subshell or a CI job to use the
+binaries hvm manages.
+`,
+	Example: `
+  eval "$(hvm env)"
+
+  hvm env --shell fish | source`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		m := EnvMeta{}
+		userHome, err := userHomeDir()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("cannot access home directory with error: %v", err))
+			os.Exit(1)
+		}
+		m.UserHome = userHome
+		m.HvmHome = HvmDataDir(m.UserHome)
+		m.Shell = envShell
+		if m.Shell == "" {
+			m.Shell = filepath.Base(os.Getenv("SHELL"))
+		}
+		// Assume a POSIX shell, which is the most likely place to evaluate the output
+		if m.Shell == "" || m.Shell == "." {
+			m.Shell = "sh"
+		}
+		lines, err := envLines(m.Shell, userBinDir(m.UserHome), os.Getenv("PATH"), m.HvmHome)
+		if err != nil {
+			fmt.Fprintln(cmd.ErrOrStderr(), fmt.Sprintf("Cannot print environment with error: %v", err))
+			os.Exit(hvm.ExitUnsupported)
+		}
+		for _, l := range lines {
+			fmt.Fprintln(cmd.OutOrStdout(), l)
+		}
+	},
+}
+
+// Initialize the command
+func init() {
+	rootCmd.AddCommand(envCmd)
+	envCmd.Flags().StringVar(&envShell,
+		"shell",
+		"",
+		"shell to print commands for instead of the one named by $SHELL (bash, fish, sh or zsh)")
+}
+
+// envLines returns the commands for shell which prepend binDir to the PATH
+// given as path unless it is already on it, and export HVM_HOME as hvmHome when
+// the hvm home was overridden for this invocation
+func envLines(shell string, binDir string, path string, hvmHome string) ([]string, error) {
+	onPath := false
+	for _, dir := range filepath.SplitList(path) {
+		if filepath.Clean(dir) == filepath.Clean(binDir) {
+			onPath = true
+			break
+		}
+	}
+	exportHome := hvmHomeOverride() != ""
+	lines := []string{}
+	switch shell {
+	case "bash", "sh", "zsh", "dash", "ksh":
+		if exportHome {
+			lines = append(lines, fmt.Sprintf("export HVM_HOME=%s", shellQuote(hvmHome)))
+		}
+		if !onPath {
+			lines = append(lines, fmt.Sprintf("export PATH=%s:\"$PATH\"", shellQuote(binDir)))
+		}
+	case "fish":
+		if exportHome {
+			lines = append(lines, fmt.Sprintf("set -gx HVM_HOME %s", shellQuote(hvmHome)))
+		}
+		if !onPath {
+			lines = append(lines, fmt.Sprintf("set -gx PATH %s $PATH", shellQuote(binDir)))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported shell %q; give one of bash, fish, sh or zsh with --shell", shell)
+	}
+	return lines, nil
+}
+
+// shellQuote quotes s with single quotes for POSIX shells and fish
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,57 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+)
+
+// networkError marks err as a failure to reach or download from a remote site
+func networkError(err error) error {
+	return &hvm.ExitError{Code: hvm.ExitNetwork, Err: err}
+}
+
+// unsupportedError marks err as caused by an unsupported binary or platform
+func unsupportedError(err error) error {
+	return &hvm.ExitError{Code: hvm.ExitUnsupported, Err: err}
+}
+
+// exitCode returns the exit code carried by err, or ExitFailure
+func exitCode(err error) int {
+	// An interrupt cancels whatever request was in flight, which would
+	// otherwise be reported as a failure of that request
+	if errors.Is(err, context.Canceled) {
+		return hvm.ExitInterrupted
+	}
+	var e *hvm.ExitError
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return hvm.ExitFailure
+}
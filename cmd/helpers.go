@@ -29,63 +29,172 @@
 package cmd
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"golang.org/x/net/html"
 	"io"
-	"io/ioutil"
-	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strings"
-	"time"
 
+	"github.com/brianshumate/hvm/pkg/hvm"
 	"github.com/hashicorp/go-hclog"
-	"github.com/hashicorp/go-version"
 	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
 )
 
-const (
-	// CheckpointURLBase is the URL base for CheckPoint API
-	CheckpointURLBase string = "https://checkpoint-api.hashicorp.com"
+// HvmRepo is the GitHub repository which publishes hvm releases
+const HvmRepo string = "brianshumate/hvm"
 
-	// ReleaseURLBase is the URL base for the HashiCorp releases website
-	ReleaseURLBase string = "https://releases.hashicorp.com"
+// HvmDataDir returns the directory holding installed binaries, logs and caches;
+// this is the directory given with --home or HVM_HOME when either is set, otherwise
+// $XDG_DATA_HOME/hvm when XDG_DATA_HOME is set, unless only a legacy $HOME/.hvm
+// exists, which keeps working so existing installations are not broken
+func HvmDataDir(userHome string) string {
+	if home := hvmHomeOverride(); home != "" {
+		return home
+	}
+	return hvm.DataDir(userHome)
+}
 
-	// VaultReleaseURLBase is the URL base for the Vault releases page
-	VaultReleaseURLBase string = "https://releases.hashicorp.com/vault/"
+// userHomeDir returns the user home directory without any trailing separator;
+// the root directory is refused unless --home or HVM_HOME gives hvm a
+// directory of its own
+func userHomeDir() (string, error) {
+	userHome, err := hvm.UserHome()
+	if errors.Is(err, hvm.ErrRootHome) && hvmHomeOverride() != "" {
+		return userHome, nil
+	}
+	return userHome, err
+}
 
-	// Consul binary name
-	Consul string = "consul"
+// hvmHomeOverride returns the absolute path of the hvm home directory given with
+// the --home flag, or else the HVM_HOME environment variable, or an empty string
+// when neither is set
+func hvmHomeOverride() string {
+	home := viper.GetString("hvm_home")
+	if home == "" {
+		return ""
+	}
+	if expanded, err := homedir.Expand(home); err == nil {
+		home = expanded
+	}
+	// Binaries are linked into the home directory, so the links must not
+	// depend on the directory hvm was run from
+	if abs, err := filepath.Abs(home); err == nil {
+		home = abs
+	}
+	return home
+}
 
-	// ConsulTemplate binary name
-	ConsulTemplate string = "consul-template"
+// CreateHvmHome creates the data directory hvmHome along with any missing
+// parents; a permission error, as when the home directory is read only or owned
+// by another user, is explained with what to do about it
+func CreateHvmHome(hvmHome string) error {
+	err := os.MkdirAll(hvmHome, 0755)
+	if err == nil {
+		return nil
+	}
+	if os.IsPermission(err) {
+		return fmt.Errorf("Cannot create directory %s; permission denied. Check the ownership and permissions of %s, or use --home or HVM_HOME to give a writable directory for hvm to keep its data in instead", hvmHome, filepath.Dir(hvmHome))
+	}
+	return fmt.Errorf("Cannot create directory %s with error: %v", hvmHome, err)
+}
 
-	// EnvConsul binary name
-	EnvConsul string = "envconsul"
+// HvmConfigDir returns the directory holding the configuration and alias files;
+// this is the --home or HVM_HOME directory when either is set, otherwise
+// $XDG_CONFIG_HOME/hvm when XDG_CONFIG_HOME is set, unless only a legacy
+// $HOME/.hvm/hvm.yaml exists
+func HvmConfigDir(userHome string) string {
+	if home := hvmHomeOverride(); home != "" {
+		return home
+	}
+	return hvm.ConfigDir(userHome)
+}
 
-	// Nomad binary name
-	Nomad string = "nomad"
+// LogFilePath returns the log file to use, which is the log_file setting or
+// --log-file flag when given and hvm.log in the data directory hvmHome otherwise
+func LogFilePath(hvmHome string) string {
+	if logFile := viper.GetString("log_file"); logFile != "" {
+		return logFile
+	}
+	return fmt.Sprintf("%s/hvm.log", hvmHome)
+}
 
-	// Packer binary name
-	Packer string = "packer"
+// logFileWriter appends everything written to it to the log file at path,
+// opening it for each write so that the data directory need not exist yet
+type logFileWriter struct {
+	path string
+}
 
-	// Sentinel binary name
-	Sentinel string = "sentinel"
+// Write implements io.Writer
+func (w *logFileWriter) Write(b []byte) (int, error) {
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return f.Write(b)
+}
 
-	// Terraform binary name
-	Terraform string = "terraform"
+// newLogger returns a logger writing to the log file w at the INFO level; with
+// --verbose it logs down to the DEBUG level and to stderr as well as to w, and
+// with log_format set to json it writes each line as a JSON object
+func newLogger(w io.Writer) hclog.Logger {
+	level := hclog.LevelFromString("INFO")
+	if verbose {
+		level = hclog.Debug
+		w = io.MultiWriter(w, rootCmd.ErrOrStderr())
+	}
+	return hclog.New(&hclog.LoggerOptions{
+		Name:       "hvm",
+		Level:      level,
+		Output:     w,
+		JSONFormat: viper.GetString("log_format") == "json",
+	})
+}
 
-	// Vagrant binary name
-	Vagrant string = "vagrant"
+// RotateLog renames logFile to logFile.1, shifting older rotated files along
+// and removing those beyond keep, once it has grown larger than maxBytes
+func RotateLog(logFile string, maxBytes int64, keep int) error {
+	fi, err := os.Stat(logFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if maxBytes <= 0 || fi.Size() <= maxBytes {
+		return nil
+	}
+	if keep < 1 {
+		return os.Remove(logFile)
+	}
+	os.Remove(fmt.Sprintf("%s.%d", logFile, keep))
+	for i := keep - 1; i >= 1; i-- {
+		older := fmt.Sprintf("%s.%d", logFile, i)
+		if _, err := os.Stat(older); err == nil {
+			if err := os.Rename(older, fmt.Sprintf("%s.%d", logFile, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Rename(logFile, fmt.Sprintf("%s.1", logFile))
+}
 
-	// Vault binary name
-	Vault string = "vault"
-)
+// VersionFromEnv returns the version of binary given by its HVM_<BINARY>_VERSION
+// environment variable, such as HVM_TERRAFORM_LS_VERSION for terraform-ls
+func VersionFromEnv(binary string) string {
+	name := strings.ToUpper(strings.Replace(binary, "-", "_", -1))
+	return strings.TrimSpace(os.Getenv(fmt.Sprintf("HVM_%s_VERSION", name)))
+}
+
+// DefaultVersion returns the version of binary given by the defaults setting,
+// such as defaults: {terraform: 1.0.11}, or an empty string if it gives none
+func DefaultVersion(binary string) string {
+	return strings.TrimSpace(viper.GetStringMapString("defaults")[binary])
+}
 
 // HelpersMeta contains data for use by the helper functions
 type HelpersMeta struct {
@@ -99,18 +208,18 @@ type HelpersMeta struct {
 	HvmHome             string
 }
 
-// ActiveLocalVersion tries to locate binary tools in the system path and get their version using OS calls
-// 'consul version' has a slightly different output style from the others, and must be handled differently
-func ActiveLocalVersion(binary string) (string, error) {
+// CheckActiveVersion tries to locate binary tools in the system path and get their version using OS calls;
+// the version is returned without the surrounding whitespace of the output so that it can be compared
+func CheckActiveVersion(binary string) (string, error) {
 	activeVersion := ""
-	userHome, err := homedir.Dir()
+	userHome, err := userHomeDir()
 	if err != nil {
 		return activeVersion, fmt.Errorf("Cannot determine user home directory with error: %v", err)
 	}
 	m := HelpersMeta{}
 	m.UserHome = userHome
-	m.HvmHome = fmt.Sprintf("%s/.hvm", m.UserHome)
-	m.LogFile = fmt.Sprintf("%s/hvm.log", m.HvmHome)
+	m.HvmHome = HvmDataDir(m.UserHome)
+	m.LogFile = LogFilePath(m.HvmHome)
 	m.BinaryArch = runtime.GOARCH
 	m.BinaryOS = runtime.GOOS
 	m.BinaryName = binary
@@ -119,298 +228,155 @@ func ActiveLocalVersion(binary string) (string, error) {
 		return "", fmt.Errorf("Cannot open log file %s with error: %v", m.LogFile, err)
 	}
 	defer f.Close()
-	w := bufio.NewWriter(f)
-	logger := hclog.New(&hclog.LoggerOptions{Name: "hvm", Level: hclog.LevelFromString("INFO"), Output: w})
+	logger := newLogger(f)
 	binPath, err := exec.LookPath(binary)
 	if err != nil {
 		logger.Error("helper", "cannot detect binary on PATH", binary, "error", err.Error())
 		return "", fmt.Errorf("Cannot detect binary on PATH with error: %v", err)
 	}
-	var version []byte
-	if binary == Consul {
-		version, err = exec.Command("/bin/sh", "-c", fmt.Sprintf("%s version | head -n 1 | awk '{print $2}' | cut -d 'v' -f2", binPath)).Output()
-		if err != nil {
-			logger.Error("helper", "cannot execute binary", binary, "error", err.Error())
-			return "", fmt.Errorf("Cannot execute binary with error: %v", err)
-		}
-		return string(version), nil
-	} else {
-		version, err = exec.Command("/bin/sh", "-c", fmt.Sprintf("%s version | awk '{print $2}' | cut -d 'v' -f2", binPath)).Output()
-		if err != nil {
-			logger.Error("helper", "cannot execute binary", binary, "error", err.Error())
-			return "", fmt.Errorf("Cannot execute binary with error: %v", err)
-		}
-		return string(version), nil
+	// Only the first line holds the version; 'consul version' goes on to list
+	// protocol versions and 'terraform version' can warn it is out of date
+	version, err := exec.Command("/bin/sh", "-c", fmt.Sprintf("%s version | head -n 1 | awk '{print $2}' | cut -d 'v' -f2", binPath)).Output()
+	if err != nil {
+		logger.Error("helper", "cannot execute binary", binary, "error", err.Error())
+		return "", fmt.Errorf("Cannot execute binary with error: %v", err)
 	}
+	return strings.TrimSpace(string(version)), nil
 }
 
-// HMLTData returns bits of HTML Data
-func HTMLData(URL string) ([]byte, error) {
-	userHome, err := homedir.Dir()
-	if err != nil {
-		return nil, fmt.Errorf("Cannot determine user home directory with error: %v", err)
-	}
-	m := HelpersMeta{}
-	m.UserHome = userHome
-	m.HvmHome = fmt.Sprintf("%s/.hvm", m.UserHome)
-	m.LogFile = fmt.Sprintf("%s/hvm.log", m.HvmHome)
-	f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return nil, fmt.Errorf("Cannot open log file %s with error: %v", m.LogFile, err)
-	}
-	defer f.Close()
-	w := bufio.NewWriter(f)
-	logger := hclog.New(&hclog.LoggerOptions{Name: "hvm", Level: hclog.LevelFromString("INFO"), Output: w})
-	response, err := http.Get(URL)
-	if err != nil {
-		logger.Error("helper", "Cannot fetch data with error", err.Error())
-		return nil, fmt.Errorf("cannot fetch data with error: %v", err)
+// humanBytes formats a byte count as a human readable string, e.g. 12.3 MB
+func humanBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
 	}
-	defer response.Body.Close()
-	if response.StatusCode != http.StatusOK {
-		err = errors.New(response.Status)
-		logger.Error("helper", "Cannot fetch data with error", err.Error())
-		return nil, fmt.Errorf("cannot failed to fetch data with error: %v", err)
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
 	}
-	var fetchData bytes.Buffer
-	_, err = io.Copy(&fetchData, response.Body)
-	if err != nil {
-		logger.Error("helper", "cannot fetch data with error", err.Error())
-		return nil, fmt.Errorf("Cannot fetch data with bytes buffer with error: %v", err)
-	}
-	return fetchData.Bytes(), nil
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
 }
 
-// LatestReleaseVersion returns the latest available binary version from releases.hashicorp.com
-func LatestReleaseVersion(binary string) (string, error) {
-	userHome, err := homedir.Dir()
-	if err != nil {
-		return "", fmt.Errorf("Cannot determine user home directory with error: %v", err)
-	}
-	m := HelpersMeta{}
-	m.UserHome = userHome
-	m.HvmHome = fmt.Sprintf("%s/.hvm", m.UserHome)
-	m.LogFile = fmt.Sprintf("%s/hvm.log", m.HvmHome)
-	f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return "", fmt.Errorf("Cannot open log file %s with error: %v", m.LogFile, err)
-	}
-	defer f.Close()
-	w := bufio.NewWriter(f)
-	logger := hclog.New(&hclog.LoggerOptions{Name: "hvm", Level: hclog.LevelFromString("INFO"), Output: w})
-	logger.Debug("helper", "f-get-latest-version", binary)
-	switch binary {
-	// Some binary latest versions cannot be queried through the Checkpoint API.
-	// Those binaries must unfortunately be queried using an HTML scraping approach instead.
-	case Vault:
-		logger.Debug("helper", "f-get-latest-version-html-scrape-url-base", VaultReleaseURLBase)
-		logger.Debug("helper", "f-get-latest-version-html-scrape-binary-name", binary)
-		var found bool
-		resp, err := http.Get(VaultReleaseURLBase)
-		if err != nil {
-			return "", fmt.Errorf("Cannot get Vault release URL with error: %v", err)
-		}
-		defer resp.Body.Close()
-		z := html.NewTokenizer(bufio.NewReader(resp.Body))
-		for found == false {
-			tt := z.Next()
-			switch tt {
-			case html.ErrorToken:
-				return "", err
-			case html.StartTagToken:
-				t := z.Token()
-				switch t.Data {
-				case "a":
-					z.Next()
-					t = z.Token()
-					if t.Data != "../" {
-						latestVersion := strings.TrimPrefix(t.Data, "vault_")
-						m.BinaryLatestVersion = latestVersion
-						found = true
-						break
-					}
-				default:
-					continue
-				}
-			}
-		}
-	case Consul, Nomad, Packer, Vagrant, Terraform:
-		logger.Debug("helper", "f-get-latest-version-checkpoint-url-base", CheckpointURLBase)
-		logger.Debug("helper", "f-get-latest-version-checkpoint-binary-name", binary)
-		checkpointDataURL := fmt.Sprintf("%s/v1/check/%s", CheckpointURLBase, binary)
-		logger.Debug("helper", "f-get-latest-version-checkpoint-data-url", checkpointDataURL)
-		checkPointClient := http.Client{Timeout: time.Second * 2}
-		req, err := http.NewRequest(http.MethodGet, checkpointDataURL, nil)
-		if err != nil {
-			logger.Error("helper", "f-get-latest-version", "request-error", err.Error())
-			return "", err
-		}
-		req.Header.Set("User-Agent", "hvm-oss-http-client")
-		res, err := checkPointClient.Do(req)
-		if err != nil {
-			logger.Error("helper", "f-get-latest-version", "get-error", err.Error())
-			return "", err
-		}
-		body, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			logger.Error("helper", "f-get-latest-version", "read-body-error", err.Error())
-			return "", err
-		}
-		err = json.Unmarshal(body, &m)
+// DiskUsage returns the total size in bytes of all regular files under path
+func DiskUsage(path string) (int64, error) {
+	var total int64
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
 		if err != nil {
-			logger.Error("helper", "f-get-latest-version", "json-unmarshall-error", err.Error())
-			return "", fmt.Errorf("cannot unmarshal JSON with error: %v", err)
+			return err
 		}
-		// Ensure that we get something like a valid version back from the API
-		// and not a maintenance page or similar...
-		checkpointLatestVersion, err := version.NewVersion(m.BinaryLatestVersion)
-		if err != nil {
-			logger.Error("helper", "issue", "cannot determine comparison version", "error", err.Error())
-			return "", err
-		}
-		constraints, err := version.NewConstraint(">= 0.0.1")
-		if err != nil {
-			logger.Error("helper", "f-get-latest-version", "issue", "cannot determine comparison constraints", "error", err.Error())
-			return "", err
-		}
-		if constraints.Check(checkpointLatestVersion) {
-			logger.Debug("helper", "f-get-latest-version", "chcked-version", "version", checkpointLatestVersion, "constraints", constraints)
-		} else {
-			// Eh oh, something is wrong!
-			logger.Error("helper", "f-get-latest-version", "issue", "unexpected-checkpoint-api-value", m.BinaryLatestVersion)
-			return "", fmt.Errorf("problem determining latest binary version")
-		}
-		return m.BinaryLatestVersion, nil
-	default:
-		if m.BinaryName != Vault {
-			logger.Warn("helper", "binary", m.BinaryName, "unsupported-binary", "Binary not in CheckPoint API or otherwise not supported.")
-			return "", fmt.Errorf("Binary currently unsupported")
+		if info.Mode().IsRegular() {
+			total += info.Size()
 		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("Cannot determine disk usage of %s with error: %v", path, err)
 	}
-	return m.BinaryLatestVersion, nil
+	return total, nil
 }
 
-// InstalledVersion determines if specified binary version is already installed by hvm
-func InstalledVersion(binary string, checkVersion string) (bool, error) {
-	installedVersion := false
-	m := HelpersMeta{}
-	userHome, err := homedir.Dir()
-	if err != nil {
-		return installedVersion, fmt.Errorf("Unable to determine user home directory; error: %v", err)
-	}
-	m.UserHome = userHome
-	m.HvmHome = fmt.Sprintf("%s/.hvm", m.UserHome)
-	m.LogFile = fmt.Sprintf("%s/hvm.log", m.HvmHome)
-	m.BinaryArch = runtime.GOARCH
-	m.BinaryCheckVersion = checkVersion
-	m.BinaryOS = runtime.GOOS
-	m.BinaryName = binary
-	if _, err := os.Stat(m.HvmHome); os.IsNotExist(err) {
-		err = os.Mkdir(m.HvmHome, 0755)
-		if err != nil {
-			return false, fmt.Errorf("failed to create directory %s with error: %v", m.HvmHome, err)
-		}
-	}
-	f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// SymlinkedVersion returns the version of binary which the hvm managed symbolic link
+// in the user bin directory points to, or an empty string if there is none
+func SymlinkedVersion(binary string) (string, error) {
+	userHome, err := userHomeDir()
 	if err != nil {
-		return installedVersion, fmt.Errorf("Failed to open log file with error: %v", err)
+		return "", fmt.Errorf("Unable to determine user home directory; error: %v", err)
 	}
-	defer f.Close()
-	w := bufio.NewWriter(f)
-	logger := hclog.New(&hclog.LoggerOptions{Name: "hvm", Level: hclog.LevelFromString("INFO"), Output: w})
-	logger.Debug("helper", "is-installed-version", m.BinaryName, "check version", m.BinaryCheckVersion)
-	fullPath := fmt.Sprintf("%s/%s/%s", m.HvmHome, m.BinaryName, m.BinaryCheckVersion)
-	// :phew:
-	_, err = os.Stat(fullPath)
+	linkPath := fmt.Sprintf("%s/bin/%s", userHome, binary)
+	target, err := os.Readlink(linkPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			installedVersion = false
+			return "", nil
 		}
-	} else {
-		installedVersion = true
+		return "", fmt.Errorf("Cannot read symbolic link %s with error: %v", linkPath, err)
+	}
+	// Links created by hvm take the form <data dir>/<binary>/<version>/<binary>
+	binaryPath := fmt.Sprintf("%s/%s/", HvmDataDir(userHome), binary)
+	if !strings.HasPrefix(target, binaryPath) {
+		return "", nil
 	}
-	return installedVersion, nil
+	return filepath.Base(filepath.Dir(target)), nil
 }
 
-// LocalVersionList gets a list of locally installed versions
-// func LocalVersionList(binary string) ([]string, error) {
-
-// }
+// ManagedLink is a symbolic link in a bin directory which hvm made, pointing at
+// an installed version of a binary
+type ManagedLink struct {
+	Path    string
+	Binary  string
+	Version string
+}
 
-// ValidVersion accepts a binary name and version number then validates it against all versions
-// from releases.hashicorp.com returning true if the proposed version number matches a version
-// listed there or false if not found or an error occurs
-func ValidVersion(binary string, binaryVersion string) (bool, error) {
-	validVersion := false
-	m := HelpersMeta{}
-	userHome, err := homedir.Dir()
-	if err != nil {
-		return validVersion, fmt.Errorf("Unable to determine user home directory; error: %v", err)
+// ManagedLinks returns every link in the user bin directory and global_bin_dir
+// which points at a version installed in the data directory hvmHome, including
+// links made with --bin-name, so that no version a link still leads to is
+// removed
+func ManagedLinks(userHome string, hvmHome string) ([]ManagedLink, error) {
+	binDirs := []string{userBinDir(userHome)}
+	if global := viper.GetString("global_bin_dir"); global != "" && filepath.Clean(global) != binDirs[0] {
+		binDirs = append(binDirs, filepath.Clean(global))
 	}
-	m.UserHome = userHome
-	m.HvmHome = fmt.Sprintf("%s/.hvm", m.UserHome)
-	m.LogFile = fmt.Sprintf("%s/hvm.log", m.HvmHome)
-	m.BinaryArch = runtime.GOARCH
-	m.BinaryCheckVersion = binaryVersion
-	m.BinaryOS = runtime.GOOS
-	m.BinaryName = binary
-	if _, err := os.Stat(m.HvmHome); os.IsNotExist(err) {
-		err = os.Mkdir(m.HvmHome, 0755)
+	links := []ManagedLink{}
+	for _, binDir := range binDirs {
+		entries, err := os.ReadDir(binDir)
 		if err != nil {
-			return false, fmt.Errorf("failed to create directory %s with error: %v", m.HvmHome, err)
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("Cannot read %s with error: %v", binDir, err)
+		}
+		for _, e := range entries {
+			if e.Type()&os.ModeSymlink != os.ModeSymlink {
+				continue
+			}
+			linkPath := filepath.Join(binDir, e.Name())
+			target, err := os.Readlink(linkPath)
+			if err != nil {
+				return nil, fmt.Errorf("Cannot read symbolic link %s with error: %v", linkPath, err)
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(binDir, target)
+			}
+			// Links created by hvm take the form <data dir>/<binary>/<version>/<binary>
+			rel, err := filepath.Rel(hvmHome, target)
+			if err != nil {
+				continue
+			}
+			parts := strings.Split(rel, string(filepath.Separator))
+			if len(parts) != 3 || parts[0] == ".." || parts[0] != parts[2] {
+				continue
+			}
+			links = append(links, ManagedLink{Path: linkPath, Binary: parts[0], Version: parts[1]})
 		}
 	}
-	f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	return links, nil
+}
+
+// ShadowingPath returns the path found for binary on PATH when it is not the hvm
+// managed symbolic link in binDir, such as a system installed binary earlier
+// on PATH, or an empty string if the link is found first
+func ShadowingPath(binDir string, binary string) (string, error) {
+	linkPath := fmt.Sprintf("%s/%s", binDir, binary)
+	linkInfo, err := os.Lstat(linkPath)
 	if err != nil {
-		return validVersion, fmt.Errorf("Failed to open log file with error: %v", err)
+		return "", fmt.Errorf("Cannot read symbolic link %s with error: %v", linkPath, err)
 	}
-	defer f.Close()
-	w := bufio.NewWriter(f)
-	logger := hclog.New(&hclog.LoggerOptions{Name: "hvm", Level: hclog.LevelFromString("INFO"), Output: w})
-	logger.Info("helper", "validateversion", m.BinaryName, "check version", m.BinaryCheckVersion)
-	binaryVersions := []string{}
-	var foundVersions bool
-	resp, err := http.Get(fmt.Sprintf("%s/%s", ReleaseURLBase, m.BinaryName))
+	found, err := exec.LookPath(binary)
 	if err != nil {
-		logger.Error("helper", "failed to open validateversion url with error", err.Error())
-		return validVersion, fmt.Errorf("failed to get url with error: %v", err)
+		return "", fmt.Errorf("%s is not found on PATH; add %s to PATH", binary, binDir)
 	}
-	defer resp.Body.Close()
-	z := html.NewTokenizer(bufio.NewReader(resp.Body))
-	for foundVersions == false {
-		tt := z.Next()
-		switch tt {
-		case html.ErrorToken:
-			return false, nil
-		case html.StartTagToken:
-			t := z.Token()
-			switch t.Data {
-			case "a":
-				z.Next()
-				t = z.Token()
-				version := strings.TrimPrefix(t.Data, fmt.Sprintf("%s_", binary))
-				// strip "../" from inclusion into the slice
-				if version == "../" {
-					continue
-				}
-				binaryVersions = append(binaryVersions, version)
-				if version == "0.1.0" {
-					// we are at the bottom of the versions list now
-					foundVersions = true
-					break
-				}
-			}
-		default:
-			continue
-		}
+	foundInfo, err := os.Lstat(found)
+	if err == nil && os.SameFile(linkInfo, foundInfo) {
+		return "", nil
 	}
-	// we have relatively small slices, so...
-	logger.Info("helper", "Versions", binaryVersions)
-	for _, n := range binaryVersions {
-		if binaryVersion == n {
-			validVersion = true
-			return validVersion, nil
-		}
+	return found, nil
+}
+
+// didYouMean formats suggestions from SuggestVersions as a sentence to append to an error
+// message, or returns an empty string when there are none
+func didYouMean(suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
 	}
-	return validVersion, nil
+	return fmt.Sprintf(" Did you mean %s?", strings.Join(suggestions, " or "))
 }
@@ -0,0 +1,142 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+	"github.com/spf13/viper"
+)
+
+// testHome points the home and hvm home directories at a new temporary
+// directory which is returned
+func testHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("HVM_HOME", home)
+	viper.AutomaticEnv()
+	return home
+}
+
+// testPath sets PATH to a new temporary directory which is returned, holding
+// only the tools CheckActiveVersion runs, so that binaries installed on the
+// host cannot be found
+func testPath(t *testing.T) string {
+	t.Helper()
+	binDir := t.TempDir()
+	for _, tool := range []string{"awk", "cut", "head"} {
+		toolPath, err := exec.LookPath(tool)
+		if err != nil {
+			t.Skipf("cannot find %s on PATH", tool)
+		}
+		if err := os.Symlink(toolPath, filepath.Join(binDir, tool)); err != nil {
+			t.Fatalf("cannot link %s with error: %v", tool, err)
+		}
+	}
+	t.Setenv("PATH", binDir)
+	return binDir
+}
+
+// fakeBinary writes a shell script named binary to dir which prints output
+func fakeBinary(t *testing.T, dir string, binary string, output string) {
+	t.Helper()
+	script := "#!/bin/sh\nprintf '" + output + "'\n"
+	if err := os.WriteFile(filepath.Join(dir, binary), []byte(script), 0755); err != nil {
+		t.Fatalf("cannot write fake %s with error: %v", binary, err)
+	}
+}
+
+func TestCheckActiveVersion(t *testing.T) {
+	testHome(t)
+	binDir := testPath(t)
+	cases := []struct {
+		binary string
+		output string
+		want   string
+	}{
+		{hvm.Vault, `Vault v1.2.3 ('\''0123abcd'\'')\n`, "1.2.3"},
+		{hvm.Terraform, `Terraform v0.12.0\n\nYour version of Terraform is out of date!\n`, "0.12.0"},
+		{hvm.Consul, `Consul v1.4.0\nProtocol 2 spoken by default\n`, "1.4.0"},
+		{hvm.Nomad, `  Nomad v0.9.1  \r\n`, "0.9.1"},
+	}
+	for _, c := range cases {
+		t.Run(c.binary, func(t *testing.T) {
+			fakeBinary(t, binDir, c.binary, c.output)
+			got, err := CheckActiveVersion(c.binary)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestManagedLinks(t *testing.T) {
+	home := testHome(t)
+	globalDir := t.TempDir()
+	viper.Set("global_bin_dir", globalDir)
+	t.Cleanup(func() { viper.Set("global_bin_dir", nil) })
+	userDir := userBinDir(home)
+	if err := os.MkdirAll(userDir, 0755); err != nil {
+		t.Fatalf("cannot create %s with error: %v", userDir, err)
+	}
+	links := map[string]string{
+		filepath.Join(userDir, "vault"):       filepath.Join(home, "vault", "1.0.2", "vault"),
+		filepath.Join(userDir, "vault-1.0"):   filepath.Join(home, "vault", "1.0.0", "vault"),
+		filepath.Join(globalDir, "terraform"): filepath.Join(home, "terraform", "0.11.11", "terraform"),
+		filepath.Join(userDir, "consul"):      "/usr/bin/consul",
+		filepath.Join(globalDir, "nomad"):     filepath.Join(home, "nomad", "0.9.0"),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatalf("cannot link %s with error: %v", link, err)
+		}
+	}
+	got, err := ManagedLinks(home, home)
+	if err != nil {
+		t.Fatalf("ManagedLinks() error: %v", err)
+	}
+	want := map[string]string{
+		filepath.Join(userDir, "vault"):       "vault 1.0.2",
+		filepath.Join(userDir, "vault-1.0"):   "vault 1.0.0",
+		filepath.Join(globalDir, "terraform"): "terraform 0.11.11",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ManagedLinks() = %v, want %d links", got, len(want))
+	}
+	for _, l := range got {
+		if want[l.Path] != l.Binary+" "+l.Version {
+			t.Errorf("ManagedLinks() link %s = %s %s, want %q", l.Path, l.Binary, l.Version, want[l.Path])
+		}
+	}
+}
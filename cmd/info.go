@@ -26,19 +26,30 @@
 package cmd
 
 import (
-	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"runtime"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/brianshumate/hvm/pkg/hvm"
 	"github.com/hashicorp/go-hclog"
-	"github.com/mitchellh/go-homedir"
 	"github.com/ryanuber/columnize"
 	"github.com/spf13/cobra"
 )
 
+var infoCheckLatest bool
+
+// infoClock returns the time info reports as the current date and time; it is
+// a variable so that the output can be reproduced with a fixed time
+var infoClock = time.Now
+
 type InfoMeta struct {
 	CurrentConsulVersion 	string
 	CurrentNomadVersion  	string
@@ -65,31 +76,30 @@ of their popular CLI tools on supported platforms.`,
     Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 			m := InfoMeta{}
-			userHome, err := homedir.Dir()
+			userHome, err := userHomeDir()
 			if err != nil {
-				fmt.Println(fmt.Sprintf("cannot access home directory with error: %v", err))
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("cannot access home directory with error: %v", err))
 				os.Exit(1)
 			}
 			m.UserHome = userHome
-			m.HvmHome = fmt.Sprintf("%s/.hvm", m.UserHome)
-			m.LogFile = fmt.Sprintf("%s/hvm.log", m.HvmHome)
+			m.HvmHome = HvmDataDir(m.UserHome)
+			m.LogFile = LogFilePath(m.HvmHome)
 			m.HostArch = runtime.GOARCH
 			m.HostOS = runtime.GOOS
 			if _, err := os.Stat(m.HvmHome); os.IsNotExist(err) {
-				err = os.Mkdir(m.HvmHome, 0755)
+				err = CreateHvmHome(m.HvmHome)
 				if err != nil {
-				fmt.Println(fmt.Sprintf("Cannot create directory %s with error: %v", m.HvmHome, err))
+				fmt.Fprintln(cmd.OutOrStdout(), err)
 				os.Exit(1)
 				}
 			}
 			f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 			if err != nil {
-				fmt.Println(fmt.Sprintf("Cannot open log file %s with error: %v", m.LogFile, err))
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot open log file %s with error: %v", m.LogFile, err))
 				os.Exit(1)
 			}
 			defer f.Close()
-			w := bufio.NewWriter(f)
-			logger := hclog.New(&hclog.LoggerOptions{Name: "hvm", Level: hclog.LevelFromString("INFO"), Output: w})
+			logger := newLogger(f)
 
             // System info
             hostName, err := os.Hostname()
@@ -98,40 +108,48 @@ of their popular CLI tools on supported platforms.`,
 			}
 			m.HostName = hostName
 			s := map[string]string{"OS": m.HostOS, "Architecture": m.HostArch}
-			t := time.Now()
+			t := infoClock()
 			s["Date/Time"] = t.Format("Mon Jan _2 15:04:05 2006")
 			si := []string{}
 			for k, v := range s {
 				si = append(si, fmt.Sprintf("%s: | %s ", k, v))
 			}
-			// sort.Strings(si)
+			sort.Strings(si)
 			systemData := columnize.SimpleFormat(si)
 
 			// Version info
 			v := map[string]string{}
-			consulV, err := ActiveLocalVersion(Consul)
+			consulV, err := reportedVersion(hvm.Consul, cmd.ErrOrStderr())
 			if err != nil {
 				logger.Error("info", "cannot determine version", "consul", "error", err.Error())
 			}
 			if consulV != "" {
 				m.CurrentConsulVersion = consulV
-				v["Consul"] = m.CurrentConsulVersion
+				v["Consul"] = m.CurrentConsulVersion + pathConflict(cmd.ErrOrStderr(), m.UserHome, hvm.Consul)
             }
-			nomadV, err := ActiveLocalVersion(Nomad)
+			nomadV, err := reportedVersion(hvm.Nomad, cmd.ErrOrStderr())
 			if err != nil {
 				logger.Error("info", "cannot determine version", "nomad", "error", err.Error())
 			}
 			if nomadV != "" {
 				m.CurrentNomadVersion = nomadV
-				v["Nomad"] = m.CurrentNomadVersion
+				v["Nomad"] = m.CurrentNomadVersion + pathConflict(cmd.ErrOrStderr(), m.UserHome, hvm.Nomad)
             }
-			vaultV, err := ActiveLocalVersion(Vault)
+			vaultV, err := reportedVersion(hvm.Vault, cmd.ErrOrStderr())
 			if err != nil {
 				logger.Error("info", "cannot determine version", "vault", "error", err.Error())
 			}
 			if vaultV != "" {
 				m.CurrentVaultVersion = vaultV
-				v["Vault"] = m.CurrentVaultVersion
+				v["Vault"] = m.CurrentVaultVersion + pathConflict(cmd.ErrOrStderr(), m.UserHome, hvm.Vault)
+			}
+			if infoCheckLatest {
+				latest := latestVersions(cmd.Context(), v, logger)
+				for k := range v {
+					if latest[k] != "" {
+						v[k] = fmt.Sprintf("%s (latest: %s)", v[k], latest[k])
+					}
+				}
 			}
             vi := []string{}
 			for k, v := range v {
@@ -141,16 +159,78 @@ of their popular CLI tools on supported platforms.`,
 			versionData := columnize.SimpleFormat(vi)
 
             // Display all
-			fmt.Println("System Factoids")
-			fmt.Println("")
-			fmt.Println(systemData)
-			fmt.Println("")
-			fmt.Println("Installed Versions")
-			fmt.Println("")
-			fmt.Println(versionData)
+			fmt.Fprintln(cmd.OutOrStdout(), "System Factoids")
+			fmt.Fprintln(cmd.OutOrStdout(), "")
+			fmt.Fprintln(cmd.OutOrStdout(), systemData)
+			fmt.Fprintln(cmd.OutOrStdout(), "")
+			fmt.Fprintln(cmd.OutOrStdout(), "Installed Versions")
+			fmt.Fprintln(cmd.OutOrStdout(), "")
+			fmt.Fprintln(cmd.OutOrStdout(), versionData)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(infoCmd)
+	infoCmd.PersistentFlags().BoolVar(&infoCheckLatest,
+		"check-latest",
+		false,
+		"also look up the latest available version of each installed binary")
+}
+
+// latestVersions concurrently looks up the latest version of each binary named
+// by the keys of versions; binaries which cannot be looked up are left out
+func latestVersions(ctx context.Context, versions map[string]string, logger hclog.Logger) map[string]string {
+	latest := map[string]string{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for k := range versions {
+		wg.Add(1)
+		go func(k string) {
+			defer wg.Done()
+			lv, err := hvm.GetLatestVersion(ctx, strings.ToLower(k))
+			if err != nil {
+				logger.Error("info", "cannot determine latest version", k, "error", err.Error())
+				return
+			}
+			mu.Lock()
+			latest[k] = lv
+			mu.Unlock()
+		}(k)
+	}
+	wg.Wait()
+	return latest
+}
+
+// pathConflict compares the version of binary which the hvm managed link points
+// to with the version reported by whichever binary is run from PATH; when they
+// differ, as when a binary earlier on PATH shadows the link, a warning
+// explaining the conflict is written to warnings and a note naming the version
+// PATH runs is returned to show next to the linked version, and otherwise an
+// empty string is returned
+func pathConflict(warnings io.Writer, userHome string, binary string) string {
+	linked, err := SymlinkedVersion(binary)
+	if err != nil || linked == "" {
+		return ""
+	}
+	pathVersion, err := CheckActiveVersion(binary)
+	if err != nil || pathVersion == "" || pathVersion == linked {
+		return ""
+	}
+	found, err := exec.LookPath(binary)
+	if err != nil {
+		return ""
+	}
+	fmt.Fprintln(warnings, fmt.Sprintf("Warning: hvm links %s version %s in %s, but running %s from PATH runs %s, which reports version %s; put %s before %s on PATH to use the version hvm manages.", binary, linked, userBinDir(userHome), binary, found, pathVersion, userBinDir(userHome), filepath.Dir(found)))
+	return fmt.Sprintf(" (PATH runs %s)", pathVersion)
+}
+
+// reportedVersion returns the active version of binary as recorded by hvm, or
+// for a binary hvm has never made active, the version of whichever binary is
+// first on PATH; warnings about the active version are written to warnings
+func reportedVersion(binary string, warnings io.Writer) (string, error) {
+	v, err := ActiveVersion(binary, warnings)
+	if err != nil || v != "" {
+		return v, err
+	}
+	return CheckActiveVersion(binary)
 }
@@ -26,55 +26,109 @@
 package cmd
 
 import (
-	"bufio"
-	"bytes"
-	"errors"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/briandowns/spinner"
-	"github.com/hashicorp/go-getter"
-	"github.com/hashicorp/go-hclog"
+	"github.com/brianshumate/hvm/pkg/hvm"
 	"github.com/hashicorp/go-version"
-	"github.com/mitchellh/go-homedir"
+	"github.com/ryanuber/columnize"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // InstallMeta contains data for a binary installation candidate
 type InstallMeta struct {
-	BinaryArch           string
-	BinaryName           string
-	BinaryOS             string
-	BinaryDesiredVersion string
-	BinaryLatestVersion  string `json:"current_version"`
-	LogFile              string
-	UserHome             string
-	HvmHome              string
+	BinaryArch             string
+	BinaryName             string
+	BinaryOS               string
+	BinaryDesiredVersion   string
+	BinaryInstalledVersion string
+	BinaryLatestVersion    string `json:"current_version"`
+	Concurrent             bool
+	DryRun                 bool
+	Force                  bool
+	FollowSymlinks         bool
+	Idempotent             bool
+	IncludePrerelease      bool
+	Interactive            bool
+	JSON                   bool
+	KeepArchive            bool
+	Out                    io.Writer
+	Err                    io.Writer
+	Parallel               int
+	Result                 *hvm.InstallResult
+	Source                 string
+	SourceChecksum         string
+	Use                    bool
+	Global                 bool
+	VersionFile            string
+	LogFile                string
+	UserHome               string
+	HvmHome                string
 }
 
-var binaryVersion string
+var (
+	binaryVersion string
+	installOS     string
+	installArch   string
+	installDryRun bool
+
+	installIncludePrerelease bool
+	installUse               bool
+	installForce             bool
+	installSource            string
+	installChecksum          string
+	installJSON              bool
+	installVersionFile       string
+	installGlobal            bool
+	installFollowSymlinks    bool
+	installIdempotent        bool
+	installInteractive       bool
+	installParallel          int
+	installKeepArchive       bool
+)
+
+// maxParallel caps --parallel so that batch installs stay polite to the release server
+const maxParallel = 8
 
 // installCmd downloads, extracts, and installs a binary into the hvm home path
 var installCmd = &cobra.Command{
-	Use:   "install (<binary>) [--version <version>]",
+	Use:   "install [<binary>] [--version <version>]",
 	Short: "Install a binary at latest available or specified version",
 	Long: `
 Install a supported binary binary at specified version for the host detected
-architecture and operating system; if the version flag is omitted, the latest
-available version will be installed.
+architecture and operating system; if the version flag is omitted, the version
+declared for the project is installed, or else the version the defaults
+setting gives for the binary, or else the latest available version.
+
+Without a binary, every tool version declared under tools in an hvm.yaml
+project file in the current directory, or the closest parent directory with
+one, is installed and used. The .terraform-version file of tfenv and the
+.tool-versions file of asdf declare versions too.
 
 hvm can install the following binaries:
 
 * consul
+* consul-k8s
 * consul-template (WIP)
 * envconsul (WIP)
 * nomad
+* nomad-autoscaler
 * packer
 * sentinel (WIP)
+* serf
 * terraform
+* terraform-ls
 * vagrant
 * vault
 `,
@@ -83,236 +137,697 @@ hvm can install the following binaries:
 
   hvm install vault
 
-  hvm install nomad --version 0.8.5`,
-	ValidArgs: []string{"consul",
-		"consul-template",
-		"envconsul",
-		"nomad",
-		"packer",
-		"sentinel",
-		"terraform",
-		"vagrant",
-		"vault"},
+  hvm install
+
+  hvm install nomad --version 0.8.5
+
+  HVM_TERRAFORM_VERSION=0.11.11 hvm install terraform
+
+  hvm install terraform --version 0.11.11 --os linux --arch amd64
+
+  hvm install terraform --version 0.11.11 --os all --arch all
+
+  hvm install vault --dry-run
+
+  hvm install terraform --interactive
+
+  hvm install vault --version 1.0.2 --use
+
+  hvm install vault --version 1.0.2 --force
+
+  hvm install vault --version 1.0.2 --json
+
+  hvm install terraform --version-file .terraform-version
+
+  hvm install vault --version 1.0.2 --source /tmp/vault_1.0.2_linux_amd64.zip`,
+	ValidArgs: hvm.SupportedBinaries(),
 	Args: func(cmd *cobra.Command, args []string) error {
+    // Without a binary the tools declared by a project file are installed
     if len(args) < 1 {
-      return errors.New("requires at least one argument, the name of a binary to install.")
+      return nil
     }
     // Is desired binary supported?
     b := args[0]
-    s := []string{Consul, Nomad, Packer, Terraform, Vagrant, Vault}
-    for _, v := range s {
-		if v == b {
-    		return nil
-		}
+	if hvm.IsSupported(b) {
+		return nil
 	}
 	// This is not ideal. We need a custom usage that basically _is_ the `hvm install --help` output
 	// instead of the main usage; custom usage functions and templates are possible with Cobra
 	// but I have yet to give that a try...
-	return fmt.Errorf("Cannot install %s; for a list of supported binaries, use hvm install --help", b)
+	return unsupportedError(fmt.Errorf("Cannot install %q; it is not a supported binary; for a list of supported binaries, use hvm install --help", b))
   	},
 	Run: func(cmd *cobra.Command, args []string) {
-		m := InstallMeta{}
-		userHome, err := homedir.Dir()
+		m := InstallMeta{Out: cmd.OutOrStdout(), Err: cmd.ErrOrStderr()}
+		userHome, err := userHomeDir()
 		if err != nil {
-			fmt.Println(fmt.Sprintf("cannot access home directory with error: %v", err))
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("cannot access home directory with error: %v", err))
 			os.Exit(1)
 		}
 		m.UserHome = userHome
-		m.HvmHome = fmt.Sprintf("%s/.hvm", m.UserHome)
-		m.LogFile = fmt.Sprintf("%s/hvm.log", m.HvmHome)
-		m.BinaryArch = runtime.GOARCH
+		m.HvmHome = HvmDataDir(m.UserHome)
+		m.LogFile = LogFilePath(m.HvmHome)
+		m.BinaryArch = hvm.NormalizeArch(installArch)
 		m.BinaryDesiredVersion = binaryVersion
-		m.BinaryOS = runtime.GOOS
+		m.BinaryOS = installOS
+		m.DryRun = installDryRun
+		m.IncludePrerelease = installIncludePrerelease
+		m.Use = installUse
+		m.Force = installForce
+		m.Idempotent = installIdempotent
+		m.Source = installSource
+		m.SourceChecksum = installChecksum
+		m.JSON = installJSON
+		m.VersionFile = installVersionFile
+		m.Global = installGlobal
+		m.FollowSymlinks = installFollowSymlinks
+		m.Interactive = installInteractive
+		m.Parallel = installParallel
+		m.KeepArchive = installKeepArchive
+		if m.Parallel < 1 || m.Parallel > maxParallel {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot install with --parallel %d; give a number from 1 to %d.", m.Parallel, maxParallel))
+			os.Exit(1)
+		}
+		if len(args) == 0 {
+			// Only the flags which make sense for every declared tool apply
+			for _, name := range []string{"version", "os", "arch", "source", "checksum", "use", "global", "json", "version-file", "interactive"} {
+				if cmd.Flags().Changed(name) {
+					fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot use --%s when installing the tools declared by %s.", name, ProjectFileName))
+					os.Exit(1)
+				}
+			}
+			cwd, err := os.Getwd()
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine current directory with error: %v", err))
+				os.Exit(1)
+			}
+			p, _, err := FindProject(cwd)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				os.Exit(exitCode(err))
+			}
+			if p == nil || len(p.Tools) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot install; give the name of a binary to install or declare tools in a %s, %s or %s file.", ProjectFileName, TerraformVersionFileName, ToolVersionsFileName))
+				os.Exit(1)
+			}
+			if err := CreateHvmHome(m.HvmHome); err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				os.Exit(1)
+			}
+			if err := installProject(cmd.Context(), &m, p); err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				os.Exit(exitCode(err))
+			}
+			return
+		}
 		m.BinaryName = strings.Join(args, " ")
 		b := m.BinaryName
+		if m.BinaryDesiredVersion == "" && !m.Interactive {
+			m.BinaryDesiredVersion = VersionFromEnv(b)
+		}
+		if m.BinaryDesiredVersion == "" && !m.Interactive {
+			v, err := ProjectVersion(b)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				os.Exit(exitCode(err))
+			}
+			m.BinaryDesiredVersion = v
+		}
+		if m.BinaryDesiredVersion == "" && !m.Interactive {
+			m.BinaryDesiredVersion = DefaultVersion(b)
+		}
 		v := m.BinaryDesiredVersion
+		batch := m.BinaryOS == "all" || m.BinaryArch == "all"
+		if batch {
+			if m.Use || m.Source != "" || m.VersionFile != "" {
+				fmt.Fprintln(cmd.OutOrStdout(), "Cannot use --use, --source or --version-file when installing for all platforms.")
+				os.Exit(1)
+			}
+		} else if err := hvm.ValidatePlatform(m.BinaryOS, m.BinaryArch); err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot install %s with error: %v.", b, err))
+			os.Exit(hvm.ExitUnsupported)
+		}
+		if m.Source != "" && v == "" {
+			fmt.Fprintln(cmd.OutOrStdout(), "Cannot install from --source without --version.")
+			os.Exit(1)
+		}
+		if m.Interactive {
+			if v != "" || m.Source != "" || m.JSON {
+				fmt.Fprintln(cmd.OutOrStdout(), "Cannot use --interactive with --version, --source or --json.")
+				os.Exit(1)
+			}
+			if !isTerminal(os.Stdin) {
+				fmt.Fprintln(cmd.OutOrStdout(), "Cannot use --interactive without a terminal; give the version with --version instead.")
+				os.Exit(1)
+			}
+		}
+		if m.Global && !m.Use {
+			fmt.Fprintln(cmd.OutOrStdout(), "Cannot use --global without --use.")
+			os.Exit(1)
+		}
+		if m.Source == "" && m.SourceChecksum != "" {
+			fmt.Fprintln(cmd.OutOrStdout(), "Cannot use --checksum without --source.")
+			os.Exit(1)
+		}
+		if m.Use && hvm.PlatformVersion(v, m.BinaryOS, m.BinaryArch) != v {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot use %s built for %s/%s on this %s/%s host.", b, m.BinaryOS, m.BinaryArch, runtime.GOOS, runtime.GOARCH))
+			os.Exit(hvm.ExitUnsupported)
+		}
 		if _, err := os.Stat(m.HvmHome); os.IsNotExist(err) {
-			err = os.Mkdir(m.HvmHome, 0755)
+			err = CreateHvmHome(m.HvmHome)
 			if err != nil {
-			fmt.Println(fmt.Sprintf("Cannot create directory %s with error: %v", m.HvmHome, err))
+			fmt.Fprintln(cmd.OutOrStdout(), err)
 			os.Exit(1)
 			}
 		}
 		f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 		if err != nil {
-			fmt.Println(fmt.Sprintf("Failed to open log file %s with error: %v", m.LogFile, err))
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Failed to open log file %s with error: %v", m.LogFile, err))
 			os.Exit(1)
 		}
 		defer f.Close()
-		w := bufio.NewWriter(f)
-		logger := hclog.New(&hclog.LoggerOptions{Name: "hvm", Level: hclog.LevelFromString("INFO"), Output: w})
-		// Is desired binary version valid?
-		if v != "" {
-			vv, err := ValidVersion(b, v)
+		logger := newLogger(f)
+		if m.Interactive {
+			versions, err := hvm.ListRemoteVersions(cmd.Context(), b, !m.IncludePrerelease, 0)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot list %s versions with error: %v.", b, err))
+				os.Exit(exitCode(err))
+			}
+			pickedVersion, err := pickVersion(os.Stdin, cmd.OutOrStdout(), b, versions)
 			if err != nil {
-				fmt.Println(fmt.Sprintf("Cannot determine if %s version %s is valid with error %v.", b, v, err))
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot install %s with error: %v.", b, err))
 				os.Exit(1)
+			}
+			logger.Info("install", "run", b, "picked version", pickedVersion)
+			m.BinaryDesiredVersion = pickedVersion
+			v = pickedVersion
+		}
+		// Reject malformed versions before making any requests with them
+		if v != "" {
+			if err := hvm.CheckVersionFormat(v); err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot install %s with error: %v.", b, err))
+				os.Exit(hvm.ExitInvalidVersion)
+			}
+		}
+		// Resolve the latest version up front so that the installed version
+		// check below compares against a real version, and say what it is
+		// before anything is downloaded
+		if v == "" {
+			if !m.JSON {
+				fmt.Fprint(cmd.OutOrStdout(), fmt.Sprintf("Resolving latest %s version... ", b))
+			}
+			latestVersion, err := hvm.GetLatestVersion(cmd.Context(), b)
+			if err != nil {
+				if m.JSON {
+					printInstallResult(m.Out, failedInstallResult(&m, fmt.Errorf("Cannot determine latest %s version with error: %w", b, err)))
+					os.Exit(exitCode(err))
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), "failed")
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine latest %s version with error: %v.", b, err))
+				os.Exit(exitCode(err))
+			}
+			if !m.JSON {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("found %s", latestVersion))
+			}
+			logger.Info("install", "run", b, "latest version", latestVersion)
+			m.BinaryDesiredVersion = latestVersion
+			v = latestVersion
+		}
+		// Is desired binary version valid? A local source cannot be checked
+		// against releases.hashicorp.com as it may well be unreachable, and
+		// offline an installed version needs no checking while any other is
+		// refused by the install itself without a request being made.
+		if v != "" && m.Source == "" && !viper.GetBool("offline") {
+			vv, knownVersions, err := hvm.ValidateVersion(cmd.Context(), b, v)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine if %s version %s is valid with error %v.", b, v, err))
+				os.Exit(exitCode(err))
 			} else {
 				if vv == false {
-				fmt.Println(fmt.Sprintf("Cannot install %s version %s; it is not available from releases.hashicorp.com.", b, v))
-				os.Exit(1)
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot install %s version %s; it is not available from releases.hashicorp.com.%s", b, v, didYouMean(hvm.SuggestVersions(v, knownVersions))))
+				os.Exit(hvm.ExitInvalidVersion)
 				}
 			}
 		}
+		// Is desired binary version a prerelease?
+		if v != "" && !m.IncludePrerelease {
+			if err := checkPrerelease(b, v); err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("%v.", err))
+				os.Exit(exitCode(err))
+			}
+		}
+		if batch {
+			platforms := batchPlatforms(b, m.BinaryOS, m.BinaryArch)
+			if len(platforms) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot install %s; it is not published for %s/%s.", b, m.BinaryOS, m.BinaryArch))
+				os.Exit(hvm.ExitUnsupported)
+			}
+			logger.Info("install", "run", b, "desired version", v, "platforms", strings.Join(platforms, ","))
+			os.Exit(installPlatforms(cmd.Context(), &m, platforms))
+		}
 		// Is desired binary already installed?
 		var installedVersion bool
 
-		installedVersion, err = InstalledVersion(b, v)
+		installedVersion, err = hvm.IsInstalledVersion(b, hvm.PlatformVersion(v, m.BinaryOS, m.BinaryArch))
 		if err != nil {
-			fmt.Println(fmt.Sprintf("Cannot install %s with error: %v.", b, err))
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot install %s with error: %v.", b, err))
 			os.Exit(1)
 		}
-		if installedVersion == true {
-			// XXX: This is busted!
-			if v == "" {
-				fmt.Println(fmt.Sprintf("Latest %s version is already installed.", b))
-				os.Exit(1)
-			} else {
-				fmt.Println(fmt.Sprintf("%s version %s is already installed.", b, v))
-				os.Exit(1)
+		if installedVersion == true && m.Force {
+			logger.Info("install", "run", b, "desired version", v, "force", "true")
+			err = forceInstallBinary(cmd.Context(), &m)
+			if err != nil {
+				if m.JSON {
+					printInstallResult(m.Out, failedInstallResult(&m, err))
+					os.Exit(exitCode(err))
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot reinstall %s version %s with error: %v.", b, v, err))
+				os.Exit(exitCode(err))
+			}
+		} else if installedVersion == true {
+			m.Result = &hvm.InstallResult{
+				Binary:      b,
+				Version:     v,
+				OS:          m.BinaryOS,
+				Arch:        m.BinaryArch,
+				InstallPath: fmt.Sprintf("%s/%s/%s/%s", m.HvmHome, b, hvm.PlatformVersion(v, m.BinaryOS, m.BinaryArch), b),
+				Status:      "already_installed",
+			}
+			if !m.JSON {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("%s version %s is already installed.", b, v))
 			}
+			if !m.Idempotent {
+				if m.JSON {
+					printInstallResult(m.Out, m.Result)
+				}
+				os.Exit(hvm.ExitAlreadyInstalled)
+			}
+			// Carry on as after an install so that --use and --version-file
+			// still take effect for the version already there
+			logger.Info("install", "run", b, "desired version", v, "already installed", "true")
+			m.BinaryInstalledVersion = v
 		} else {
 			logger.Info("install", "run", b, "desired version", v)
-			err = installBinary(&m)
+			err = installBinary(cmd.Context(), &m)
+			if err != nil {
+				if m.JSON {
+					printInstallResult(m.Out, failedInstallResult(&m, err))
+					os.Exit(exitCode(err))
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot install %s version %s with error: %v.", b, v, err))
+				os.Exit(exitCode(err))
+			}
+		}
+		if m.VersionFile != "" && !m.DryRun {
+			// Let later pipeline steps reuse the version resolved at install time
+			if err := ioutil.WriteFile(m.VersionFile, []byte(m.BinaryInstalledVersion+"\n"), 0644); err != nil {
+				logger.Error("install", "version-file", m.VersionFile, "error", err)
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot write version file %s with error: %v", m.VersionFile, err))
+				os.Exit(1)
+			}
+		}
+		if m.Use && !m.DryRun {
+			binDir := userBinDir(m.UserHome)
+			if m.Global {
+				binDir = viper.GetString("global_bin_dir")
+				err = linkBinaryGlobal(m.HvmHome, b, m.BinaryInstalledVersion)
+			} else {
+				err = linkBinary(m.UserHome, m.HvmHome, b, m.BinaryInstalledVersion)
+			}
 			if err != nil {
-				fmt.Println(fmt.Sprintf("Cannot install %s version %s with error: %v.", b, v, err))
+				logger.Error("install", "use", "symlink", "error", err)
+				if m.JSON {
+					m.Result.Status = "failed"
+					m.Result.Error = err.Error()
+					printInstallResult(m.Out, m.Result)
+					os.Exit(1)
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot use %s version %s with error: %v", b, m.BinaryInstalledVersion, err))
 				os.Exit(1)
 			}
+			m.Result.Active = true
+			if !m.JSON {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Using %s (%s/%s) version %s", b, m.BinaryOS, m.BinaryArch, m.BinaryInstalledVersion))
+				warnShadowed(m.Err, binDir, b)
+			}
+		} else if !m.DryRun {
+			// A reinstall of the version in use leaves it active
+			active, err := ActiveVersion(b, m.Err)
+			if err == nil && active == hvm.PlatformVersion(m.BinaryInstalledVersion, m.BinaryOS, m.BinaryArch) {
+				m.Result.Active = true
+			}
+		}
+		if !m.JSON && !m.DryRun && m.Result.Status != "already_installed" {
+			printInstallSummary(&m)
+		}
+		if m.JSON {
+			if m.Force && installedVersion && m.Result.Status == "installed" {
+				m.Result.Status = "reinstalled"
+			}
+			printInstallResult(m.Out, m.Result)
 		}
 
 	},
 }
 
+// checkPrerelease returns an error with ExitInvalidVersion when v is a
+// prerelease version of binary b, which is only installed with
+// --include-prerelease
+func checkPrerelease(b string, v string) error {
+	pv, err := version.NewVersion(v)
+	if err == nil && pv.Prerelease() != "" {
+		return &hvm.ExitError{Code: hvm.ExitInvalidVersion, Err: fmt.Errorf("Cannot install %s version %s; it is a prerelease version (%s), use --include-prerelease to install it anyway", b, v, pv.Prerelease())}
+	}
+	return nil
+}
+
 // Initialize the command
 func init() {
 	rootCmd.AddCommand(installCmd)
 	installCmd.PersistentFlags().StringVar(&binaryVersion,
 		"version",
 		"",
-		"install binary version")
-	installCmd.MarkFlagRequired("version")
+		"install binary version (default is HVM_<BINARY>_VERSION, the project version, the defaults setting, or the latest)")
+	installCmd.PersistentFlags().StringVar(&installOS,
+		"os",
+		runtime.GOOS,
+		"install binary for operating system, or all")
+	installCmd.PersistentFlags().StringVar(&installArch,
+		"arch",
+		runtime.GOARCH,
+		"install binary for architecture, or all; aliases such as x86_64 or aarch64 are accepted")
+	installCmd.PersistentFlags().BoolVar(&installInteractive,
+		"interactive",
+		false,
+		"choose the version to install from a menu of available versions")
+	installCmd.PersistentFlags().BoolVar(&installDryRun,
+		"dry-run",
+		false,
+		"print what would be installed without downloading anything")
+	installCmd.PersistentFlags().BoolVar(&installIncludePrerelease,
+		"include-prerelease",
+		false,
+		"allow installing prerelease versions such as betas and release candidates")
+	installCmd.PersistentFlags().BoolVar(&installUse,
+		"use",
+		false,
+		"use the binary version once it is installed")
+	installCmd.PersistentFlags().BoolVar(&installGlobal,
+		"global",
+		false,
+		"with --use, link the binary into global_bin_dir for all users instead of ~/bin")
+	installCmd.PersistentFlags().BoolVar(&installForce,
+		"force",
+		false,
+		"remove and reinstall the binary version if it is already installed")
+	installCmd.PersistentFlags().BoolVar(&installIdempotent,
+		"idempotent",
+		false,
+		"exit 0 rather than 5 if the binary version is already installed")
+	installCmd.PersistentFlags().BoolVar(&installFollowSymlinks,
+		"follow-symlinks",
+		false,
+		"install into the target of a version directory which is a symbolic link")
+	installCmd.PersistentFlags().StringVar(&installSource,
+		"source",
+		"",
+		"install from this already downloaded zip archive path or URL instead of releases.hashicorp.com")
+	installCmd.PersistentFlags().StringVar(&installChecksum,
+		"checksum",
+		"",
+		"expected SHA-256 sum of the --source archive")
+	installCmd.PersistentFlags().BoolVar(&installJSON,
+		"json",
+		false,
+		"print the install result as JSON instead of human readable output")
+	installCmd.PersistentFlags().StringVar(&installVersionFile,
+		"version-file",
+		"",
+		"write the installed version to this file after a successful install")
+	installCmd.PersistentFlags().BoolVar(&installKeepArchive,
+		"keep-archive",
+		false,
+		"keep the verified release archive and its SHA256SUMS file under the archives directory")
+	installCmd.PersistentFlags().IntVar(&installParallel,
+		"parallel",
+		3,
+		fmt.Sprintf("number of downloads to run at once when installing for several platforms or project tools, at most %d", maxParallel))
 }
 
-// installBinary has entirely too much going on in it right now!
-// some of this needs to possibly be refactored into helpers
-func installBinary(m *InstallMeta) error {
-	b := m.BinaryName
-	v := m.BinaryDesiredVersion
-	f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("Failed to open log file %s with error: %v", m.LogFile, err)
+// batchPlatforms returns the os/arch platforms binary is published for which
+// match binaryOS and binaryArch, either of which can be "all"
+func batchPlatforms(binary string, binaryOS string, binaryArch string) []string {
+	platforms := []string{}
+	spec, ok := hvm.LookupBinary(binary)
+	if !ok {
+		return platforms
 	}
-	defer f.Close()
-	w := bufio.NewWriter(f)
-	logger := hclog.New(&hclog.LoggerOptions{Name: "hvm", Level: hclog.LevelFromString("INFO"), Output: w})
-	logger.Debug("install", "f-install-binary", "start", "with-binary", b)
-	if b == "" {
-		b = "none"
-		logger.Error("install", "unknown-binary", "GURU DEDICATION")
-		return fmt.Errorf("install: unknown binary. GURU DEDICATION")
-	}
-	if v == "" {
-		logger.Debug("install", "f-install-binary", "blank-version", "binary", b)
-		latestBinaryVersion, err := LatestReleaseVersion(b)
-		if err != nil {
-			logger.Error("install", "get-latest-version-fail", "error", err.Error())
-			return err
+	for o, archs := range spec.Platforms {
+		if binaryOS != "all" && binaryOS != o {
+			continue
 		}
-		logger.Debug("install", "get-latest-version", "inner", "got-version", latestBinaryVersion)
-		v = latestBinaryVersion
-	}
-	logger.Info("install", "install binary candidate", "final", "binary", b, "desired-version", v)
-
-	switch b {
-	case Consul, Nomad, Packer, Terraform, Vagrant, Vault:
-		targetPath := fmt.Sprintf("%s/.hvm/%s/%s", m.UserHome, b, v)
-		if _, err := os.Stat(targetPath); os.IsNotExist(err) {
-			if os.IsNotExist(err) {
-				err := os.MkdirAll(targetPath, 0770)
-				if err != nil {
-					logger.Error("install", "directory-creation-error", err.Error())
-					return fmt.Errorf("Cannot create directory %s with error: %v", targetPath, err)
-				}
+		for _, a := range archs {
+			if binaryArch != "all" && binaryArch != a {
+				continue
 			}
+			platforms = append(platforms, fmt.Sprintf("%s/%s", o, a))
 		}
-		// Store <binary>_<version>_SHA256SUMS file obtained from
-		// https://releases.hashicorp.com/<binary>/<version>/<binary>_<version>_SHA256SUMS
-		// in map for comparison
-		binaryShaURL := fmt.Sprintf("%s/%s/%s/%s_%s_SHA256SUMS", ReleaseURLBase, b, v, b, v)
-		logger.Debug("install", "sha256sums-file-url", binaryShaURL)
-		binarySha, err := HTMLData(binaryShaURL)
-		if err != nil {
-			logger.Error("install", "cannot download sha256sums with error", err.Error())
-			return err
-		}
-		shaStream := bytes.NewReader(binarySha)
-		scanner := bufio.NewScanner(shaStream)
-		fileSha := map[string]string{}
-		for scanner.Scan() {
-			s := strings.Fields(scanner.Text())
-			if len(s) == 2 {
-				if b == Nomad {
-					logger.Debug("install", "stage", "scanner", "binary", Nomad)
-					nomadLatestVersion, err := version.NewVersion(v)
-					if err != nil {
-						logger.Error("install", "issue", "cannot determine Nomad comparison version", "error", err.Error())
-						return err
-					}
-					constraints, err := version.NewConstraint(">= 0.7.0-beta1")
-					if err != nil {
-						logger.Error("install", "issue", "cannot determine Nomad version constraints", "error", err.Error())
-						return err
-					}
-					// Handle the current Nomad SHA256SUMS style
-					if constraints.Check(nomadLatestVersion) {
-						logger.Debug("install", "newer-nomad-version", nomadLatestVersion, "constraints", constraints)
-						fileSha[strings.Trim(s[1], "./")] = s[0]
-					} else {
-						// Handle older Nomad SHA256SUMS style
-						fileSha[s[1]] = s[0]
-					}
+	}
+	sort.Strings(platforms)
+	return platforms
+}
+
+// installPlatforms installs the desired version for each os/arch platform, up to
+// m.Parallel of them at once, prints a per platform summary and returns the exit
+// code to use
+func installPlatforms(ctx context.Context, m *InstallMeta, platforms []string) int {
+	code := hvm.ExitOK
+	statuses := make([]string, len(platforms))
+	platformResults := make([]*hvm.InstallResult, len(platforms))
+	failed := make([]bool, len(platforms))
+	parallel := m.Parallel
+	// Plans printed by a dry run must not interleave
+	if m.DryRun || parallel < 1 {
+		parallel = 1
+	}
+	runParallel(ctx, len(platforms), parallel, func(i int) {
+		pm := *m
+		parts := strings.SplitN(platforms[i], "/", 2)
+		pm.BinaryOS, pm.BinaryArch = parts[0], parts[1]
+		pm.Result = nil
+		pm.Concurrent = parallel > 1 && len(platforms) > 1
+		status := "installed"
+		installed, err := hvm.IsInstalledVersion(pm.BinaryName, hvm.PlatformVersion(pm.BinaryDesiredVersion, pm.BinaryOS, pm.BinaryArch))
+		if err == nil && installed && !pm.Force {
+			status = "already installed"
+			pm.Result = &hvm.InstallResult{
+				Binary:  pm.BinaryName,
+				Version: pm.BinaryDesiredVersion,
+				OS:      pm.BinaryOS,
+				Arch:    pm.BinaryArch,
+				Status:  "already_installed",
+			}
+		} else {
+			if err == nil {
+				if installed {
+					err = forceInstallBinary(ctx, &pm)
 				} else {
-					logger.Debug("install", "binary", b)
+					err = installBinary(ctx, &pm)
 				}
 			}
-			fileSha[s[1]] = s[0]
-		}
-		if err := scanner.Err(); err != nil {
-			logger.Error("install", "process-sha256sums-error", err.Error())
-			return err
-		}
-		pkgFilename := fmt.Sprintf("%s_%s_%s_%s.zip", b, v, m.BinaryOS, m.BinaryArch)
-		checkSha := fileSha[pkgFilename]
-		fullURL := fmt.Sprintf("%s/%s/%s/%s?checksum=sha256:%s", ReleaseURLBase, b, v, pkgFilename, checkSha)
-		installPath := fmt.Sprintf("%s/%s", targetPath, b)
-		logger.Debug("install", "valid-binary", "true", "full-url", fullURL, "install-path", installPath)
-		// Shout out to Ye Olde School BSD spinner!
-		hvmSpinnerSet := []string{"/", "|", "\\", "-", "|", "\\", "-"}
-		s := spinner.New(hvmSpinnerSet, 174*time.Millisecond)
-		s.Writer = os.Stderr
-		err = s.Color("fgHiCyan")
+			if err != nil {
+				status = fmt.Sprintf("failed: %v", err)
+				pm.Result = failedInstallResult(&pm, err)
+				failed[i] = true
+			} else if pm.DryRun {
+				status = "planned"
+			}
+		}
+		statuses[i] = status
+		platformResults[i] = pm.Result
+	})
+	summary := []string{"Platform | Status"}
+	results := []*hvm.InstallResult{}
+	for i, p := range platforms {
+		// The batch stops on interrupt rather than failing every remaining
+		// platform, so platforms which were never started are left out
+		if statuses[i] == "" {
+			continue
+		}
+		if failed[i] {
+			code = hvm.ExitFailure
+		}
+		if platformResults[i] != nil {
+			results = append(results, platformResults[i])
+		}
+		summary = append(summary, fmt.Sprintf("%s | %s", p, statuses[i]))
+	}
+	if m.JSON {
+		out, err := json.MarshalIndent(results, "", "  ")
 		if err != nil {
-			logger.Debug("install", "weird-error", err.Error())
-		}
-		s.Suffix = " Installing..."
-		s.FinalMSG = fmt.Sprintf("Installed %s (%s/%s) version %s\n", b, m.BinaryOS, m.BinaryArch, v)
-		s.Start()
-		logger.Debug("install", "status", "go-getter", "download-url", fullURL)
-		logger.Debug("install", "status", "go-getter", "install-path", installPath)
-		// Get binary archive using go-getter from a URL which takes the form of:
-		// 'https://releases.hashicorp.com/<binary>/<version>/<binary>_<version>_<os>_<arch>.zip
-		// go-getter validates the intended download against its published SHA256 summary before downloading, or fails if the there is mismatch / other issue which prevents comparison.
-		if err := getter.GetFile(installPath, fullURL); err != nil {
-			fmt.Printf("Download error with %q", err)
-			// If the SHA don't match or we hit any issue, then we ain't dancing!
-			logger.Error("install", "download-zip-error", err.Error())
-			s.Stop()
-			return err
+			fmt.Fprintln(m.Out, fmt.Sprintf("Cannot encode install results with error: %v", err))
+			return hvm.ExitFailure
+		}
+		fmt.Fprintln(m.Out, string(out))
+		return code
+	}
+	fmt.Fprintln(m.Out, "")
+	fmt.Fprintln(m.Out, columnize.SimpleFormat(summary))
+	return code
+}
+
+// runParallel calls fn with each index from 0 to n-1, running at most parallel
+// calls at once, and waits for them to finish; no further calls are started
+// once ctx is done
+func runParallel(ctx context.Context, n int, parallel int, fn func(i int)) {
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			break
 		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// printInstallSummary prints what a completed install changed as a table
+func printInstallSummary(m *InstallMeta) {
+	r := m.Result
+	active := "no"
+	if r.Active {
+		active = "yes"
+	}
+	summary := []string{
+		fmt.Sprintf("Binary: | %s", r.Binary),
+		fmt.Sprintf("Version: | %s", r.Version),
+		fmt.Sprintf("Platform: | %s/%s", r.OS, r.Arch),
+		fmt.Sprintf("Install path: | %s", r.InstallPath),
+		fmt.Sprintf("Downloaded: | %s", humanBytes(r.Downloaded)),
+		fmt.Sprintf("Active: | %s", active),
+	}
+	fmt.Fprintln(m.Out, "")
+	fmt.Fprintln(m.Out, "Install Summary")
+	fmt.Fprintln(m.Out, "")
+	fmt.Fprintln(m.Out, columnize.SimpleFormat(summary))
+}
+
+// printInstallResult prints an install result as a JSON object
+func printInstallResult(w io.Writer, r *hvm.InstallResult) {
+	out, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		fmt.Fprintln(w, fmt.Sprintf("Cannot encode install result with error: %v", err))
+		os.Exit(1)
+	}
+	fmt.Fprintln(w, string(out))
+}
+
+// failedInstallResult describes an install which failed with err
+func failedInstallResult(m *InstallMeta, err error) *hvm.InstallResult {
+	return &hvm.InstallResult{
+		Binary:  m.BinaryName,
+		Version: m.BinaryDesiredVersion,
+		OS:      m.BinaryOS,
+		Arch:    m.BinaryArch,
+		Status:  "failed",
+		Error:   err.Error(),
+	}
+}
+
+// forceInstallBinary removes any existing installation of the desired binary
+// version and installs it again
+func forceInstallBinary(ctx context.Context, m *InstallMeta) error {
+	return runInstall(ctx, m, true)
+}
+
+// installBinary installs the desired binary version
+func installBinary(ctx context.Context, m *InstallMeta) error {
+	return runInstall(ctx, m, false)
+}
+
+// runInstall installs the binary version described by m, showing a spinner
+// and the download progress as it goes unless the result is printed as JSON,
+// and prints the plan instead of installing anything for a dry run
+func runInstall(ctx context.Context, m *InstallMeta, force bool) error {
+	b := m.BinaryName
+	opts := &hvm.InstallOptions{
+		Binary:         b,
+		Version:        m.BinaryDesiredVersion,
+		OS:             m.BinaryOS,
+		Arch:           m.BinaryArch,
+		Source:         m.Source,
+		SourceChecksum: m.SourceChecksum,
+		DryRun:         m.DryRun,
+		Force:          force,
+		FollowSymlinks: m.FollowSymlinks,
+		Global:         m.Global,
+		KeepArchive:    m.KeepArchive,
+	}
+	// Shout out to Ye Olde School BSD spinner!
+	hvmSpinnerSet := []string{"/", "|", "\\", "-", "|", "\\", "-"}
+	s := spinner.New(hvmSpinnerSet, 174*time.Millisecond)
+	s.Writer = m.Err
+	// The spinner checks its file for a terminal, which would otherwise be
+	// stdout; output captured by anything other than a file gets no spinner
+	if f, ok := m.Err.(*os.File); ok {
+		s.WriterFile = f
+	} else {
+		s.Disable()
+	}
+	if colorEnabled(m.Err) {
+		s.Color("fgHiCyan")
+	}
+	s.Suffix = " Installing..."
+	if !m.JSON && !m.DryRun {
+		dp := newDownloadProgress(s, "Downloading", m.Err)
+		// Spinners of concurrent installs would overwrite each other, so
+		// those report progress as lines labelled with what is downloading
+		if m.Concurrent {
+			dp.prefix = fmt.Sprintf("Downloading %s (%s/%s) version %s", b, m.BinaryOS, m.BinaryArch, m.BinaryDesiredVersion)
+			dp.lines = true
+		} else {
+			s.Start()
+		}
+		opts.Progress = dp
+	}
+	r, err := hvm.Install(ctx, opts)
+	if err != nil {
 		s.Stop()
+		return err
+	}
+	m.Result = r
+	if m.DryRun {
+		if m.JSON {
+			return nil
+		}
+		plan := []string{
+			fmt.Sprintf("Binary: | %s", b),
+			fmt.Sprintf("Version: | %s", r.Version),
+			fmt.Sprintf("Platform: | %s/%s", r.OS, r.Arch),
+			fmt.Sprintf("Download URL: | %s", r.URL),
+			fmt.Sprintf("SHA256: | %s", r.SHA256),
+			fmt.Sprintf("Install path: | %s", r.InstallPath),
+		}
+		fmt.Fprintln(m.Out, "Install Plan (dry run)")
+		fmt.Fprintln(m.Out, "")
+		fmt.Fprintln(m.Out, columnize.SimpleFormat(plan))
 		return nil
-	default:
-		logger.Warn("install", "binary", b, "unsupported-binary", "not in CheckPoint API")
-		return fmt.Errorf("Cannot install %s; it is not currently supported; for a list of supported binaries, use hvm install --help", b)
 	}
+	// The final message is only set on success so that stopping the
+	// spinner on a failure or an interrupt leaves the terminal clean
+	s.FinalMSG = fmt.Sprintf("Installed %s (%s/%s) version %s\n", b, r.OS, r.Arch, r.Version)
+	s.Stop()
+	if m.Concurrent && !m.JSON {
+		fmt.Fprint(m.Err, s.FinalMSG)
+	}
+	for _, w := range r.Warnings {
+		fmt.Fprintln(m.Err, fmt.Sprintf("Warning: %s", w))
+	}
+	m.BinaryInstalledVersion = r.Version
+	return nil
 }
@@ -26,36 +26,146 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"time"
 
+	"github.com/brianshumate/hvm/pkg/hvm"
+	"github.com/ryanuber/columnize"
 	"github.com/spf13/cobra"
 )
 
+// ListMeta contains data for listing locally installed binary versions
+type ListMeta struct {
+	BinaryNames []string
+	JSON        bool
+	LogFile     string
+	UserHome    string
+	HvmHome     string
+}
+
+// ListEntry is an installed binary version as printed by list --json
+type ListEntry struct {
+	Binary      string     `json:"binary"`
+	Version     string     `json:"version"`
+	Active      bool       `json:"active"`
+	InstallPath string     `json:"install_path"`
+	InstalledAt *time.Time `json:"installed_at"`
+}
+
+var listJSON bool
+
 // listCmd represents the list command
 var listCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List locally and remotely available binary versions",
+	Use:   "list [<binary>...]",
+	Short: "List locally installed binary versions",
 	Long: `
-List both locally installed versions and all versions published to
-releases.hashicorp.com for the specified binary name:
+List the locally installed versions of the specified binaries, or of every
+supported binary if none are specified, along with when each version was
+installed and which version is in use. With --json, a JSON array of objects
+with binary, version, active, install_path and installed_at fields is printed
+instead, for editors and other tooling; installed_at is null when unknown.
 
-hvm list vault
+To list the versions published to releases.hashicorp.com, use hvm versions.
 `,
+	Example: `
+  hvm list
+
+  hvm list vault
+
+  hvm list --json`,
+	ValidArgs: hvm.SupportedBinaries(),
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("FIXME: list called, but not yet implemented.")
+		m := ListMeta{}
+		userHome, err := userHomeDir()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot access home directory with error: %v", err))
+			os.Exit(1)
+		}
+		m.UserHome = userHome
+		m.HvmHome = HvmDataDir(m.UserHome)
+		m.LogFile = LogFilePath(m.HvmHome)
+		m.BinaryNames = args
+		m.JSON = listJSON
+		if len(m.BinaryNames) == 0 {
+			m.BinaryNames = hvm.SupportedBinaries()
+		}
+		if _, err := os.Stat(m.HvmHome); os.IsNotExist(err) {
+			err = CreateHvmHome(m.HvmHome)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				os.Exit(1)
+			}
+		}
+		f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot open log file %s with error: %v", m.LogFile, err))
+			os.Exit(1)
+		}
+		defer f.Close()
+		logger := newLogger(f)
+
+		li := []string{"Binary | Version | Active | Installed"}
+		entries := []ListEntry{}
+		for _, b := range m.BinaryNames {
+			localVersions, err := hvm.ListLocalVersions(b)
+			if err != nil {
+				logger.Error("list", "binary", b, "error", err.Error())
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot list installed %s versions with error: %v", b, err))
+				os.Exit(1)
+			}
+			activeVersion, err := ActiveVersion(b, cmd.ErrOrStderr())
+			if err != nil {
+				logger.Error("list", "binary", b, "error", err.Error())
+			}
+			// Newest first
+			for i := len(localVersions) - 1; i >= 0; i-- {
+				v := localVersions[i]
+				active := ""
+				if v == activeVersion {
+					active = "*"
+				}
+				entry := ListEntry{
+					Binary:      b,
+					Version:     v,
+					Active:      v == activeVersion,
+					InstallPath: fmt.Sprintf("%s/%s/%s/%s", m.HvmHome, b, v, b),
+				}
+				installed := "unknown"
+				md, err := hvm.ReadInstallMetadata(fmt.Sprintf("%s/%s/%s", m.HvmHome, b, v))
+				if err != nil {
+					logger.Warn("list", "binary", b, "version", v, "metadata-error", err.Error())
+				}
+				if md != nil {
+					installed = md.InstalledAt.Local().Format("Mon Jan _2 15:04:05 2006")
+					entry.InstalledAt = &md.InstalledAt
+				}
+				entries = append(entries, entry)
+				li = append(li, fmt.Sprintf("%s | %s | %s | %s", b, v, active, installed))
+			}
+		}
+		if m.JSON {
+			out, err := json.MarshalIndent(entries, "", "  ")
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot encode installed versions with error: %v", err))
+				os.Exit(1)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(out))
+			return
+		}
+		if len(li) == 1 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No binary versions are installed.")
+			return
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), columnize.SimpleFormat(li))
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(listCmd)
-
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// listCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// listCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	listCmd.PersistentFlags().BoolVar(&listJSON,
+		"json",
+		false,
+		"print the installed versions as JSON instead of a table")
 }
@@ -0,0 +1,249 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// MigrateMeta contains data for migrating the legacy $HOME/.hvm directory
+type MigrateMeta struct {
+	DryRun    bool
+	LogFile   string
+	Out       io.Writer
+	Err       io.Writer
+	UserHome  string
+	Legacy    string
+	DataDir   string
+	ConfigDir string
+}
+
+// migrateMove is an entry of the legacy directory and the path it moves to
+type migrateMove struct {
+	From string
+	To   string
+}
+
+var migrateDryRun bool
+
+// migrateCmd moves the legacy $HOME/.hvm directory into the XDG directories
+var migrateCmd = &cobra.Command{
+	Use:   "migrate [--dry-run]",
+	Short: "Move the legacy $HOME/.hvm directory into the XDG directories",
+	Long: `
+Move installed binaries, state, logs and caches from the legacy $HOME/.hvm
+directory into $XDG_DATA_HOME/hvm, and the configuration, alias and binaries
+files into $XDG_CONFIG_HOME/hvm, then point the links in ~/bin and
+global_bin_dir which led into $HOME/.hvm at the new location.
+
+Only the directories whose XDG variable is set are migrated to, and nothing is
+moved should any destination already exist. Running migrate again after an
+interrupted migration carries on where it stopped.
+`,
+	Example: `
+  XDG_DATA_HOME=~/.local/share XDG_CONFIG_HOME=~/.config hvm migrate --dry-run
+
+  XDG_DATA_HOME=~/.local/share XDG_CONFIG_HOME=~/.config hvm migrate`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		m := MigrateMeta{Out: cmd.OutOrStdout(), Err: cmd.ErrOrStderr()}
+		userHome, err := userHomeDir()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot access home directory with error: %v", err))
+			os.Exit(1)
+		}
+		if hvmHomeOverride() != "" {
+			fmt.Fprintln(cmd.OutOrStdout(), "Cannot migrate with --home or HVM_HOME set; hvm uses that directory instead of the legacy or XDG directories.")
+			os.Exit(1)
+		}
+		m.UserHome = userHome
+		m.Legacy = fmt.Sprintf("%s/.hvm", m.UserHome)
+		m.DryRun = migrateDryRun
+		m.DataDir, m.ConfigDir = m.Legacy, m.Legacy
+		if base := os.Getenv("XDG_DATA_HOME"); base != "" {
+			m.DataDir = fmt.Sprintf("%s/hvm", base)
+		}
+		if base := os.Getenv("XDG_CONFIG_HOME"); base != "" {
+			m.ConfigDir = fmt.Sprintf("%s/hvm", base)
+		}
+		if m.DataDir == m.Legacy && m.ConfigDir == m.Legacy {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot migrate %s; set XDG_DATA_HOME, XDG_CONFIG_HOME or both to choose where to move it.", m.Legacy))
+			os.Exit(1)
+		}
+		if _, err := os.Stat(m.Legacy); os.IsNotExist(err) {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Nothing to migrate; %s does not exist.", m.Legacy))
+			return
+		}
+		m.LogFile = LogFilePath(HvmDataDir(m.UserHome))
+		f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot open log file %s with error: %v", m.LogFile, err))
+			os.Exit(1)
+		}
+		defer f.Close()
+		logger := newLogger(f)
+		logger.Info("migrate", "run", "start", "legacy", m.Legacy, "data", m.DataDir, "config", m.ConfigDir, "dry-run", m.DryRun)
+
+		moves, err := planMigration(&m)
+		if err != nil {
+			logger.Error("migrate", "plan", "error", err.Error())
+			fmt.Fprintln(cmd.OutOrStdout(), err)
+			os.Exit(1)
+		}
+		links, err := planRelinks(&m, []string{userBinDir(m.UserHome), viper.GetString("global_bin_dir")})
+		if err != nil {
+			logger.Error("migrate", "relink", "error", err.Error())
+			fmt.Fprintln(cmd.OutOrStdout(), err)
+			os.Exit(1)
+		}
+		if len(moves) == 0 && len(links) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Nothing to migrate; %s holds nothing which belongs elsewhere.", m.Legacy))
+			return
+		}
+		if m.DryRun {
+			for _, mv := range moves {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Would move %s to %s", mv.From, mv.To))
+			}
+			for _, l := range links {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Would point %s at %s", l.From, l.To))
+			}
+			return
+		}
+		for _, mv := range moves {
+			if err := os.MkdirAll(filepath.Dir(mv.To), 0755); err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot create directory %s with error: %v", filepath.Dir(mv.To), err))
+				os.Exit(1)
+			}
+			if err := os.Rename(mv.From, mv.To); err != nil {
+				logger.Error("migrate", "move", mv.From, "error", err.Error())
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot move %s to %s with error: %v; run hvm migrate again once the problem is fixed to move the rest.", mv.From, mv.To, err))
+				os.Exit(1)
+			}
+			logger.Info("migrate", "moved", mv.From, "to", mv.To)
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Moved %s to %s", mv.From, mv.To))
+		}
+		for _, l := range links {
+			if err := relink(l.From, l.To); err != nil {
+				logger.Error("migrate", "relink", l.From, "error", err.Error())
+				fmt.Fprintln(cmd.ErrOrStderr(), fmt.Sprintf("Warning: cannot point %s at %s with error: %v; fix the link by hand, or with hvm use (and --global) run with sufficient privileges", l.From, l.To, err))
+				continue
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Pointed %s at %s", l.From, l.To))
+		}
+		// Only succeeds once nothing is left behind
+		os.Remove(m.Legacy)
+	},
+}
+
+// Initialize the command
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.PersistentFlags().BoolVar(&migrateDryRun,
+		"dry-run",
+		false,
+		"print what would be moved and relinked without changing anything")
+}
+
+// configFiles are the files of a legacy directory which belong in the
+// configuration directory rather than the data directory
+var configFiles = map[string]bool{
+	"hvm.yaml":      true,
+	"aliases.yaml":  true,
+	"binaries.yaml": true,
+}
+
+// planMigration returns the entries of the legacy directory to move, with the
+// configuration files going to m.ConfigDir and everything else to m.DataDir,
+// or an error if any of them would replace something already there
+func planMigration(m *MigrateMeta) ([]migrateMove, error) {
+	entries, err := os.ReadDir(m.Legacy)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read %s with error: %v", m.Legacy, err)
+	}
+	moves := []migrateMove{}
+	for _, e := range entries {
+		dest := m.DataDir
+		if configFiles[e.Name()] {
+			dest = m.ConfigDir
+		}
+		if dest == m.Legacy {
+			continue
+		}
+		mv := migrateMove{From: fmt.Sprintf("%s/%s", m.Legacy, e.Name()), To: fmt.Sprintf("%s/%s", dest, e.Name())}
+		if _, err := os.Lstat(mv.To); err == nil {
+			return nil, fmt.Errorf("Cannot migrate %s; %s already exists, so move or remove one of them first.", mv.From, mv.To)
+		}
+		moves = append(moves, mv)
+	}
+	return moves, nil
+}
+
+// planRelinks returns each symbolic link in binDirs which points into the
+// legacy directory along with the target it should point at once the data
+// directory has moved
+func planRelinks(m *MigrateMeta, binDirs []string) ([]migrateMove, error) {
+	links := []migrateMove{}
+	if m.DataDir == m.Legacy {
+		return links, nil
+	}
+	prefix := m.Legacy + "/"
+	for _, dir := range binDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) || os.IsPermission(err) {
+				continue
+			}
+			return nil, fmt.Errorf("Cannot read %s with error: %v", dir, err)
+		}
+		for _, e := range entries {
+			if e.Type()&os.ModeSymlink == 0 {
+				continue
+			}
+			linkPath := fmt.Sprintf("%s/%s", dir, e.Name())
+			target, err := os.Readlink(linkPath)
+			if err != nil || !strings.HasPrefix(target, prefix) {
+				continue
+			}
+			links = append(links, migrateMove{From: linkPath, To: fmt.Sprintf("%s/%s", m.DataDir, strings.TrimPrefix(target, prefix))})
+		}
+	}
+	return links, nil
+}
+
+// relink replaces the symbolic link linkPath with one pointing at target
+func relink(linkPath string, target string) error {
+	if err := os.Remove(linkPath); err != nil {
+		return err
+	}
+	return os.Symlink(target, linkPath)
+}
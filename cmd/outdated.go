@@ -0,0 +1,148 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+	"github.com/hashicorp/go-version"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+)
+
+// OutdatedMeta contains data for comparing active and latest versions
+type OutdatedMeta struct {
+	BinaryNames []string
+	LogFile     string
+	UserHome    string
+	HvmHome     string
+}
+
+// outdatedCmd reports active binary versions which have newer releases
+var outdatedCmd = &cobra.Command{
+	Use:   "outdated [<binary>...]",
+	Short: "Report active binary versions with newer releases available",
+	Long: `
+Compare the version in use of the specified binaries, or of every supported
+binary if none are specified, with the latest version available and report
+which of them have a newer release.
+`,
+	Example: `
+  hvm outdated
+
+  hvm outdated terraform vault`,
+	ValidArgs: hvm.SupportedBinaries(),
+	Args: func(cmd *cobra.Command, args []string) error {
+		for _, b := range args {
+			if !hvm.IsSupported(b) {
+				return unsupportedError(fmt.Errorf("Cannot check %q; it is not a supported binary; for a list of supported binaries, use hvm outdated --help", b))
+			}
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		m := OutdatedMeta{}
+		userHome, err := userHomeDir()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot access home directory with error: %v", err))
+			os.Exit(1)
+		}
+		m.UserHome = userHome
+		m.HvmHome = HvmDataDir(m.UserHome)
+		m.LogFile = LogFilePath(m.HvmHome)
+		m.BinaryNames = args
+		if len(m.BinaryNames) == 0 {
+			m.BinaryNames = hvm.SupportedBinaries()
+		}
+		if _, err := os.Stat(m.HvmHome); os.IsNotExist(err) {
+			err = CreateHvmHome(m.HvmHome)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				os.Exit(1)
+			}
+		}
+		f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot open log file %s with error: %v", m.LogFile, err))
+			os.Exit(1)
+		}
+		defer f.Close()
+		logger := newLogger(f)
+
+		outdated := []string{"Binary | Current | Latest | Status"}
+		for _, b := range m.BinaryNames {
+			current, err := ActiveVersion(b, cmd.ErrOrStderr())
+			if err != nil {
+				logger.Error("outdated", "binary", b, "error", err.Error())
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine active %s version with error: %v", b, err))
+				os.Exit(1)
+			}
+			if current == "" {
+				continue
+			}
+			latest, err := hvm.GetLatestVersion(cmd.Context(), b)
+			if err != nil {
+				logger.Error("outdated", "binary", b, "error", err.Error())
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine latest %s version with error: %v", b, err))
+				os.Exit(exitCode(err))
+			}
+			status, err := outdatedStatus(current, latest)
+			if err != nil {
+				logger.Warn("outdated", "binary", b, "current", current, "latest", latest, "error", err.Error())
+			}
+			outdated = append(outdated, fmt.Sprintf("%s | %s | %s | %s", b, current, latest, status))
+		}
+		if len(outdated) == 1 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No binary versions are currently in use.")
+			return
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), columnize.SimpleFormat(outdated))
+	},
+}
+
+// Initialize the command
+func init() {
+	rootCmd.AddCommand(outdatedCmd)
+}
+
+// outdatedStatus compares the current and latest versions; go-version orders
+// a prerelease before the release it leads up to
+func outdatedStatus(current string, latest string) (string, error) {
+	cv, err := version.NewVersion(current)
+	if err != nil {
+		return "unknown", err
+	}
+	lv, err := version.NewVersion(latest)
+	if err != nil {
+		return "unknown", err
+	}
+	if cv.LessThan(lv) {
+		return "outdated", nil
+	}
+	return "up to date", nil
+}
@@ -0,0 +1,128 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/briandowns/spinner"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/viper"
+)
+
+// isTerminal returns true if v is a file attached to a terminal; any other
+// reader or writer, such as a buffer capturing command output, is not one
+func isTerminal(v any) bool {
+	f, ok := v.(*os.File)
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// colorEnabled returns true if ANSI color may be written to w; color is off
+// with --no-color, when NO_COLOR is set to any value or when w is not a terminal
+func colorEnabled(w io.Writer) bool {
+	if viper.GetBool("no_color") || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(w)
+}
+
+// downloadProgress is a go-getter ProgressTracker which reports downloaded
+// and total bytes through the install spinner when attached to a terminal,
+// or as periodic textual percentage lines otherwise
+type downloadProgress struct {
+	spinner *spinner.Spinner
+	prefix  string
+	output  io.Writer
+	// lines reports progress as lines even on a terminal, for downloads
+	// running alongside others which share the terminal
+	lines bool
+}
+
+// newDownloadProgress returns a downloadProgress which writes to output
+func newDownloadProgress(s *spinner.Spinner, prefix string, output io.Writer) *downloadProgress {
+	return &downloadProgress{spinner: s, prefix: prefix, output: output}
+}
+
+// TrackProgress implements getter.ProgressTracker
+func (p *downloadProgress) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	return &progressReader{
+		ReadCloser: stream,
+		progress:   p,
+		current:    currentSize,
+		total:      totalSize,
+		tty:        isTerminal(p.output) && !p.lines,
+	}
+}
+
+// progressReader counts bytes as they are read from a download stream
+type progressReader struct {
+	io.ReadCloser
+	sync.Mutex
+	progress    *downloadProgress
+	current     int64
+	total       int64
+	tty         bool
+	lastPercent int64
+}
+
+// Read implements io.Reader and updates the progress display
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.Lock()
+	r.current += int64(n)
+	r.report()
+	r.Unlock()
+	return n, err
+}
+
+// report updates the spinner suffix on a terminal, or prints a line for
+// each additional 10% downloaded when not attached to a terminal
+func (r *progressReader) report() {
+	if r.tty {
+		suffix := fmt.Sprintf(" %s %s", r.progress.prefix, humanBytes(r.current))
+		if r.total > 0 {
+			suffix = fmt.Sprintf(" %s %s / %s (%d%%)", r.progress.prefix, humanBytes(r.current), humanBytes(r.total), r.current*100/r.total)
+		}
+		r.progress.spinner.Lock()
+		r.progress.spinner.Suffix = suffix
+		r.progress.spinner.Unlock()
+		return
+	}
+	if r.total <= 0 {
+		return
+	}
+	percent := r.current * 100 / r.total
+	if percent >= r.lastPercent+10 || (percent == 100 && r.lastPercent != 100) {
+		r.lastPercent = percent - percent%10
+		fmt.Fprintf(r.progress.output, "%s %s / %s (%d%%)\n", r.progress.prefix, humanBytes(r.current), humanBytes(r.total), percent)
+	}
+}
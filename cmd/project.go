@@ -0,0 +1,289 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+)
+
+// ProjectFileName is the name of the file declaring the tool versions of a project
+const ProjectFileName string = "hvm.yaml"
+
+// TerraformVersionFileName is the name of the file declaring the terraform version
+// of a project for tfenv
+const TerraformVersionFileName string = ".terraform-version"
+
+// ToolVersionsFileName is the name of the file declaring the tool versions of a
+// project for asdf
+const ToolVersionsFileName string = ".tool-versions"
+
+// ProjectFile declares the binary versions a project requires
+type ProjectFile struct {
+	// Tools maps each binary to its required version
+	Tools map[string]string `yaml:"tools"`
+}
+
+// ReadProjectFile reads and validates the project file at path; YAML is decoded
+// directly rather than through viper so that an unquoted version such as 1.10
+// keeps its trailing zero instead of becoming the number 1.1
+func ReadProjectFile(path string) (*ProjectFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read project file %s with error: %v", path, err)
+	}
+	p := &ProjectFile{}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("Cannot parse project file %s with error: %v", path, err)
+	}
+	if len(p.Tools) == 0 {
+		return nil, fmt.Errorf("Project file %s declares no tools", path)
+	}
+	for b := range p.Tools {
+		if !hvm.IsSupported(b) {
+			return nil, unsupportedError(fmt.Errorf("Project file %s declares %q, which is not a supported binary", path, b))
+		}
+	}
+	if err := p.checkVersions(path); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ReadTerraformVersionFile reads the terraform version from the tfenv version file
+// at path, which is its first line other than blank lines and comments; only an
+// exact version is accepted, not the latest or min-required keywords of tfenv
+func ReadTerraformVersionFile(path string) (*ProjectFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read version file %s with error: %v", path, err)
+	}
+	p := &ProjectFile{Tools: map[string]string{}}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// tfenv accepts a leading v, which release versions do not have
+		p.Tools[hvm.Terraform] = strings.TrimPrefix(line, "v")
+		break
+	}
+	if err := p.checkVersions(path); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ReadToolVersionsFile reads the versions of supported binaries from the asdf
+// version file at path, where each line names a tool followed by one or more
+// versions, of which the first is used; tools hvm does not support are ignored,
+// as are tools asdf is told to take from the system or build from source
+func ReadToolVersionsFile(path string) (*ProjectFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read version file %s with error: %v", path, err)
+	}
+	p := &ProjectFile{Tools: map[string]string{}}
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !hvm.IsSupported(fields[0]) {
+			continue
+		}
+		v := fields[1]
+		if v == "system" || strings.HasPrefix(v, "ref:") || strings.HasPrefix(v, "path:") {
+			continue
+		}
+		p.Tools[fields[0]] = v
+	}
+	if err := p.checkVersions(path); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// checkVersions returns an error for the first version declared by the file at
+// path which is not an exact release version
+func (p *ProjectFile) checkVersions(path string) error {
+	for _, b := range p.Binaries() {
+		if err := hvm.CheckVersionFormat(p.Tools[b]); err != nil {
+			return &hvm.ExitError{Code: hvm.ExitInvalidVersion, Err: fmt.Errorf("Project file %s declares %s with error: %v", path, b, err)}
+		}
+	}
+	return nil
+}
+
+// FindProject returns the tool versions declared for the project in dir or its
+// closest parent directory with a version file, along with the files read; the
+// hvm.yaml project file is read along with the .terraform-version file of tfenv
+// and the .tool-versions file of asdf, so that repositories which already pin
+// versions for those work, and a version declared by more than one of them is
+// taken from the first in that order. A nil ProjectFile is returned if no
+// directory has a version file.
+func FindProject(dir string) (*ProjectFile, []string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	readers := []struct {
+		name string
+		read func(string) (*ProjectFile, error)
+	}{
+		{ProjectFileName, ReadProjectFile},
+		{TerraformVersionFileName, ReadTerraformVersionFile},
+		{ToolVersionsFileName, ReadToolVersionsFile},
+	}
+	for {
+		p := &ProjectFile{Tools: map[string]string{}}
+		paths := []string{}
+		for _, r := range readers {
+			path := filepath.Join(dir, r.name)
+			if fi, err := os.Stat(path); err != nil || fi.IsDir() {
+				continue
+			}
+			f, err := r.read(path)
+			if err != nil {
+				return nil, nil, err
+			}
+			for b, v := range f.Tools {
+				if _, ok := p.Tools[b]; !ok {
+					p.Tools[b] = v
+				}
+			}
+			paths = append(paths, path)
+		}
+		if len(paths) > 0 {
+			return p, paths, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil, nil
+		}
+		dir = parent
+	}
+}
+
+// ProjectVersion returns the version of binary declared for the project in the
+// current directory by any of the version files FindProject reads, or an empty
+// string if none declares one
+func ProjectVersion(binary string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("Cannot determine current directory with error: %v", err)
+	}
+	p, _, err := FindProject(cwd)
+	if err != nil || p == nil {
+		return "", err
+	}
+	return p.Tools[binary], nil
+}
+
+// Binaries returns the binaries declared by the project file in name order
+func (p *ProjectFile) Binaries() []string {
+	binaries := make([]string, 0, len(p.Tools))
+	for b := range p.Tools {
+		binaries = append(binaries, b)
+	}
+	sort.Strings(binaries)
+	return binaries
+}
+
+// installProject installs every version declared by the project file which is
+// not yet installed, or all of them with m.Force, up to m.Parallel at once, and
+// then uses them; with m.DryRun the plans for those installs are printed instead
+func installProject(ctx context.Context, m *InstallMeta, p *ProjectFile) error {
+	binaries := p.Binaries()
+	errs := make([]error, len(binaries))
+	parallel := m.Parallel
+	// Plans printed by a dry run must not interleave
+	if m.DryRun || parallel < 1 {
+		parallel = 1
+	}
+	runParallel(ctx, len(binaries), parallel, func(i int) {
+		b := binaries[i]
+		v := p.Tools[b]
+		installed, err := hvm.IsInstalledVersion(b, v)
+		if err != nil {
+			errs[i] = fmt.Errorf("Cannot determine if %s version %s is installed: %v", b, v, err)
+			return
+		}
+		im := InstallMeta{
+			BinaryName:           b,
+			BinaryDesiredVersion: v,
+			BinaryOS:             runtime.GOOS,
+			BinaryArch:           runtime.GOARCH,
+			Concurrent:           parallel > 1 && len(binaries) > 1,
+			DryRun:               m.DryRun,
+			KeepArchive:          m.KeepArchive,
+			LogFile:              m.LogFile,
+			Out:                  m.Out,
+			Err:                  m.Err,
+			UserHome:             m.UserHome,
+			HvmHome:              m.HvmHome,
+		}
+		switch {
+		case installed && m.Force:
+			err = forceInstallBinary(ctx, &im)
+		case !installed:
+			err = installBinary(ctx, &im)
+		}
+		if err != nil {
+			errs[i] = fmt.Errorf("Cannot install %s version %s with error: %w", b, v, err)
+		}
+	})
+	// Only use the declared versions once all of them are installed
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return &hvm.ExitError{Code: hvm.ExitInterrupted, Err: errors.New("interrupted; any partial download is resumed by the next install")}
+	}
+	if m.DryRun {
+		return nil
+	}
+	if err := useSet(m.UserHome, m.HvmHome, p.Tools); err != nil {
+		return err
+	}
+	for _, b := range binaries {
+		fmt.Fprintln(m.Out, fmt.Sprintf("Using %s version %s", b, p.Tools[b]))
+	}
+	return nil
+}
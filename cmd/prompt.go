@@ -0,0 +1,98 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ryanuber/columnize"
+)
+
+// pickerSize is the number of newest versions offered by pickVersion
+const pickerSize = 20
+
+// pickVersion lists the newest of versions, which are sorted newest first, as
+// a numbered menu on out and returns the version chosen on in, either by its
+// number or by typing it; an empty answer chooses the newest version
+func pickVersion(in io.Reader, out io.Writer, binary string, versions []string) (string, error) {
+	if len(versions) == 0 {
+		return "", fmt.Errorf("no versions of %s are available", binary)
+	}
+	shown := versions
+	if len(shown) > pickerSize {
+		shown = shown[:pickerSize]
+	}
+	menu := []string{}
+	for i, v := range shown {
+		menu = append(menu, fmt.Sprintf("%d) | %s", i+1, v))
+	}
+	fmt.Fprintln(out, fmt.Sprintf("Available %s versions", binary))
+	fmt.Fprintln(out, "")
+	fmt.Fprintln(out, columnize.SimpleFormat(menu))
+	fmt.Fprintln(out, "")
+	r := bufio.NewReader(in)
+	for {
+		fmt.Fprint(out, fmt.Sprintf("Version to install, by number or name [%s]: ", shown[0]))
+		answer, err := r.ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if err != nil && (err != io.EOF || answer == "") {
+			return "", errors.New("no version chosen")
+		}
+		if answer == "" {
+			return shown[0], nil
+		}
+		if n, err := strconv.Atoi(answer); err == nil {
+			if n >= 1 && n <= len(shown) {
+				return shown[n-1], nil
+			}
+			fmt.Fprintln(out, fmt.Sprintf("Choose a number from 1 to %d.", len(shown)))
+			continue
+		}
+		for _, v := range versions {
+			if v == answer {
+				return v, nil
+			}
+		}
+		fmt.Fprintln(out, fmt.Sprintf("%s is not an available version of %s.", answer, binary))
+	}
+}
+
+// confirm asks question on out and returns true only if the answer read from
+// in is yes; an empty answer, or none at all, is no
+func confirm(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprint(out, fmt.Sprintf("%s [y/N]: ", question))
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// PruneMeta contains data for pruning installed binary versions
+type PruneMeta struct {
+	BinaryNames []string
+	DryRun      bool
+	Keep        int
+	LogFile     string
+	Out         io.Writer
+	UserHome    string
+	HvmHome     string
+}
+
+var (
+	pruneKeep   int
+	pruneDryRun bool
+)
+
+// pruneCmd removes all but the newest installed versions of binaries
+var pruneCmd = &cobra.Command{
+	Use:   "prune [<binary>...] [--keep <count>] [--dry-run]",
+	Short: "Remove all but the newest installed binary versions",
+	Long: `
+Remove all but the newest installed versions of the specified binaries, or of
+every supported binary if none are specified. The version currently in use
+is never removed.
+
+The number of versions to keep defaults to 3, and can be changed with the
+--keep flag or the prune_keep configuration setting.
+`,
+	Example: `
+  hvm prune
+
+  hvm prune terraform --keep 2
+
+  hvm prune --dry-run`,
+	ValidArgs: hvm.SupportedBinaries(),
+	Args: func(cmd *cobra.Command, args []string) error {
+		for _, b := range args {
+			if !hvm.IsSupported(b) {
+				return unsupportedError(fmt.Errorf("Cannot prune %q; it is not a supported binary; for a list of supported binaries, use hvm prune --help", b))
+			}
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		m := PruneMeta{Out: cmd.OutOrStdout()}
+		userHome, err := userHomeDir()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot access home directory with error: %v", err))
+			os.Exit(1)
+		}
+		m.UserHome = userHome
+		m.HvmHome = HvmDataDir(m.UserHome)
+		m.LogFile = LogFilePath(m.HvmHome)
+		m.DryRun = pruneDryRun
+		m.Keep = viper.GetInt("prune_keep")
+		if cmd.Flags().Changed("keep") {
+			m.Keep = pruneKeep
+		}
+		if m.Keep < 1 {
+			fmt.Fprintln(cmd.OutOrStdout(), "Cannot prune with --keep less than 1.")
+			os.Exit(1)
+		}
+		m.BinaryNames = args
+		if len(m.BinaryNames) == 0 {
+			m.BinaryNames = hvm.SupportedBinaries()
+		}
+		if _, err := os.Stat(m.HvmHome); os.IsNotExist(err) {
+			err = CreateHvmHome(m.HvmHome)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				os.Exit(1)
+			}
+		}
+		f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot open log file %s with error: %v", m.LogFile, err))
+			os.Exit(1)
+		}
+		defer f.Close()
+		logger := newLogger(f)
+		logger.Info("prune", "run", "start", "binaries", strings.Join(m.BinaryNames, ","), "keep", m.Keep, "dry-run", m.DryRun)
+
+		for _, b := range m.BinaryNames {
+			err = pruneBinary(&m, b)
+			if err != nil {
+				logger.Error("prune", "binary", b, "error", err.Error())
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot prune %s with error: %v", b, err))
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+// Initialize the command
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+	viper.SetDefault("prune_keep", 3)
+	pruneCmd.PersistentFlags().IntVar(&pruneKeep,
+		"keep",
+		3,
+		"number of newest versions to keep")
+	pruneCmd.PersistentFlags().BoolVar(&pruneDryRun,
+		"dry-run",
+		false,
+		"print what would be removed without removing anything")
+}
+
+// pruneBinary removes all but the newest m.Keep installed versions of binary b
+func pruneBinary(m *PruneMeta, b string) error {
+	localVersions, err := hvm.ListLocalVersions(b)
+	if err != nil {
+		return err
+	}
+	if len(localVersions) <= m.Keep {
+		return nil
+	}
+	links, err := ManagedLinks(m.UserHome, m.HvmHome)
+	if err != nil {
+		return err
+	}
+	// Any version an hvm link leads to is in use, not only the active one
+	inUse := map[string]string{}
+	for _, l := range links {
+		if l.Binary == b {
+			inUse[l.Version] = l.Path
+		}
+	}
+	// localVersions is sorted oldest first
+	for _, v := range localVersions[:len(localVersions)-m.Keep] {
+		if linkPath, ok := inUse[v]; ok {
+			fmt.Fprintln(m.Out, fmt.Sprintf("Keeping %s version %s; it is currently in use by %s", b, v, linkPath))
+			continue
+		}
+		versionPath := fmt.Sprintf("%s/%s/%s", m.HvmHome, b, v)
+		if m.DryRun {
+			fmt.Fprintln(m.Out, fmt.Sprintf("Would remove %s version %s (%s)", b, v, versionPath))
+			continue
+		}
+		if err := os.RemoveAll(versionPath); err != nil {
+			return fmt.Errorf("failed to remove %s with error: %v", versionPath, err)
+		}
+		fmt.Fprintln(m.Out, fmt.Sprintf("Removed %s version %s", b, v))
+	}
+	return nil
+}
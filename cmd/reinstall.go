@@ -0,0 +1,120 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+	"github.com/spf13/cobra"
+)
+
+// reinstallCmd downloads the version of a binary currently in use again
+var reinstallCmd = &cobra.Command{
+	Use:   "reinstall (<binary>)",
+	Short: "Reinstall the binary version currently in use",
+	Long: `
+Remove and download again the version of a binary currently in use, then
+point the symbolic link at the fresh installation. This is useful when a
+binary has been corrupted or a bad download is suspected.
+`,
+	Example: `
+  hvm reinstall vault`,
+	ValidArgs: hvm.SupportedBinaries(),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("requires exactly one argument, the name of a binary to reinstall.")
+		}
+		if !hvm.IsSupported(args[0]) {
+			return unsupportedError(fmt.Errorf("Cannot reinstall %q; it is not a supported binary; for a list of supported binaries, use hvm install --help", args[0]))
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		m := InstallMeta{Out: cmd.OutOrStdout(), Err: cmd.ErrOrStderr()}
+		userHome, err := userHomeDir()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot access home directory with error: %v", err))
+			os.Exit(1)
+		}
+		m.UserHome = userHome
+		m.HvmHome = HvmDataDir(m.UserHome)
+		m.LogFile = LogFilePath(m.HvmHome)
+		m.BinaryArch = runtime.GOARCH
+		m.BinaryOS = runtime.GOOS
+		m.BinaryName = args[0]
+		m.Force = true
+		b := m.BinaryName
+		if _, err := os.Stat(m.HvmHome); os.IsNotExist(err) {
+			err = CreateHvmHome(m.HvmHome)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				os.Exit(1)
+			}
+		}
+		f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot open log file %s with error: %v", m.LogFile, err))
+			os.Exit(1)
+		}
+		defer f.Close()
+		logger := newLogger(f)
+
+		v, err := SymlinkedVersion(b)
+		if err != nil {
+			logger.Error("reinstall", "binary", b, "error", err.Error())
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine the %s version in use with error: %v", b, err))
+			os.Exit(1)
+		}
+		if v == "" {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("No %s version is in use; use one with: hvm use %s --version <version>", b, b))
+			os.Exit(1)
+		}
+		m.BinaryDesiredVersion = v
+		logger.Info("reinstall", "run", b, "version", v)
+		err = forceInstallBinary(cmd.Context(), &m)
+		if err != nil {
+			logger.Error("reinstall", "binary", b, "version", v, "error", err.Error())
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot reinstall %s version %s with error: %v", b, v, err))
+			os.Exit(1)
+		}
+		err = linkBinary(m.UserHome, m.HvmHome, b, v)
+		if err != nil {
+			logger.Error("reinstall", "symlink", "error", err.Error())
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot use %s version %s with error: %v", b, v, err))
+			os.Exit(1)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Using %s (%s/%s) version %s", b, m.BinaryOS, m.BinaryArch, v))
+	},
+}
+
+// Initialize the command
+func init() {
+	rootCmd.AddCommand(reinstallCmd)
+}
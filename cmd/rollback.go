@@ -0,0 +1,135 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+	"github.com/spf13/cobra"
+)
+
+// rollbackCmd points a binary back at the version which was active before
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback (<binary>)",
+	Short: "Go back to the previously active binary version",
+	Long: `
+Point the symbolic link of a binary back at the version which was active
+before the current one, as recorded by hvm use, hvm upgrade and the other
+commands which change the active version. Running it again goes further
+back. The previous version must still be installed.
+`,
+	Example: `
+  hvm rollback terraform`,
+	ValidArgs: hvm.SupportedBinaries(),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("requires exactly one argument, the name of a binary to roll back.")
+		}
+		if !hvm.IsSupported(args[0]) {
+			return unsupportedError(fmt.Errorf("Cannot roll back %q; it is not a supported binary; for a list of supported binaries, use hvm use --help", args[0]))
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		b := args[0]
+		userHome, err := userHomeDir()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot access home directory with error: %v", err))
+			os.Exit(1)
+		}
+		hvmHome := HvmDataDir(userHome)
+		logFile := LogFilePath(hvmHome)
+		if _, err := os.Stat(hvmHome); os.IsNotExist(err) {
+			err = CreateHvmHome(hvmHome)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				os.Exit(1)
+			}
+		}
+		f, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot open log file %s with error: %v", logFile, err))
+			os.Exit(1)
+		}
+		defer f.Close()
+		logger := newLogger(f)
+
+		from, to, err := rollbackBinary(userHome, hvmHome, b)
+		if err != nil {
+			logger.Error("rollback", "binary", b, "error", err.Error())
+			fmt.Fprintln(cmd.OutOrStdout(), err)
+			os.Exit(1)
+		}
+		logger.Info("rollback", "binary", b, "from", from, "to", to)
+		fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Rolled back %s from version %s to version %s", b, from, to))
+		warnShadowed(cmd.ErrOrStderr(), userBinDir(userHome), b)
+	},
+}
+
+// Initialize the command
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+}
+
+// rollbackBinary links binary b at the last version in its history and makes
+// that the active version, removing it from the history, and returns the
+// versions rolled back from and to
+func rollbackBinary(userHome string, hvmHome string, b string) (string, string, error) {
+	s, err := ReadState(hvmHome)
+	if err != nil {
+		return "", "", err
+	}
+	history := s.History[b]
+	if len(history) == 0 {
+		return "", "", fmt.Errorf("No earlier %s version is recorded, so there is nothing to roll back to; versions become part of the history once replaced by hvm use", b)
+	}
+	current := s.Active[b]
+	previous := history[len(history)-1]
+	installed, err := hvm.IsInstalledVersion(b, previous)
+	if err != nil {
+		return "", "", fmt.Errorf("Cannot determine if %s version %s is installed: %v", b, previous, err)
+	}
+	if !installed {
+		return "", "", fmt.Errorf("Cannot roll back to %s version %s; it is no longer installed. Install it with: hvm install %s --version %s, then run hvm rollback %s again", b, previous, b, previous, b)
+	}
+	// The state is changed here rather than through linkBinary, which would
+	// add the version rolled back from to the history
+	if err := linkBinaryIn(userBinDir(userHome), hvmHome, b, previous); err != nil {
+		return "", "", err
+	}
+	s.Active[b] = previous
+	s.History[b] = history[:len(history)-1]
+	if len(s.History[b]) == 0 {
+		delete(s.History, b)
+	}
+	if err := s.Write(hvmHome); err != nil {
+		return "", "", err
+	}
+	return current, previous, nil
+}
@@ -26,15 +26,24 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"time"
 
+	"github.com/brianshumate/hvm/pkg/hvm"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-var cfgFile string
+var (
+	cfgFile  string
+	logFile  string
+	insecure bool
+	verbose  bool
+)
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -46,40 +55,144 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// Cancel in flight network requests and downloads on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		// Restore the default behavior so a second interrupt exits immediately
+		stop()
+	}()
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
 		// Avoid double error message when using custom Arg function
 		// fmt.Println(err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.hvm/hvm.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $XDG_CONFIG_HOME/hvm/hvm.yaml or $HOME/.hvm/hvm.yaml)")
 	viper.SetDefault("author", "Brian Shumate <brian@brianshumate.com>")
 	viper.SetDefault("license", "2-Clause BSD")
+	viper.SetDefault("cache_ttl", "1h")
+	viper.SetDefault("version_source", "releases")
+	viper.SetDefault("disable_checkpoint", false)
+	// HVM_DISABLE_CHECKPOINT is accepted too, being the name policies tend to ask for
+	viper.BindEnv("disable_checkpoint", "HVM_DISABLE_CHECKPOINT", "DISABLE_CHECKPOINT")
+	viper.SetDefault("metadata_timeout", "30s")
+	viper.SetDefault("download_timeout", "30m")
+	viper.SetDefault("download_stall_timeout", "1m")
+	// HVM_CA_BUNDLE is accepted too, so as not to pick up another tool's CA_BUNDLE by surprise
+	viper.BindEnv("ca_bundle", "HVM_CA_BUNDLE", "CA_BUNDLE")
+	viper.SetDefault("offline", false)
+	// Only HVM_OFFLINE, since a bare OFFLINE is too likely to mean something else
+	viper.BindEnv("offline", "HVM_OFFLINE")
+	viper.SetDefault("global_bin_dir", "/usr/local/bin")
+	viper.SetDefault("log_max_size", 10)
+	viper.SetDefault("log_keep", 3)
+	viper.SetDefault("log_format", "text")
+	rootCmd.PersistentFlags().String("home", "", "hvm home directory for data and configuration (default is $HVM_HOME, or else the XDG or $HOME/.hvm directories)")
+	viper.BindPFlag("hvm_home", rootCmd.PersistentFlags().Lookup("home"))
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "log file (default is hvm.log in the hvm data directory)")
+	viper.BindPFlag("log_file", rootCmd.PersistentFlags().Lookup("log-file"))
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "also write the log to stderr, including debug messages")
+	rootCmd.PersistentFlags().BoolVar(&insecure, "insecure", false, "skip TLS certificate verification (dangerous; prefer ca_bundle)")
+	rootCmd.PersistentFlags().Bool("offline", false, "never use the network, working only from what is already installed (also set by HVM_OFFLINE)")
+	viper.BindPFlag("offline", rootCmd.PersistentFlags().Lookup("offline"))
+	rootCmd.PersistentFlags().Bool("no-color", false, "disable colored output (also disabled by setting NO_COLOR)")
+	viper.BindPFlag("no_color", rootCmd.PersistentFlags().Lookup("no-color"))
+	rootCmd.PersistentFlags().Duration("timeout-metadata", 30*time.Second, "timeout for version lookups and other small requests")
+	viper.BindPFlag("metadata_timeout", rootCmd.PersistentFlags().Lookup("timeout-metadata"))
+	rootCmd.PersistentFlags().Duration("timeout-download", 30*time.Minute, "timeout for downloading a binary archive, or 0 for none")
+	viper.BindPFlag("download_timeout", rootCmd.PersistentFlags().Lookup("timeout-download"))
 }
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
+	// Use any matching environment variables, which HVM_HOME must be among
+	// before the configuration directory is looked for
+	viper.AutomaticEnv()
 	if cfgFile != "" {
 		// Use configuration file from flag
 		viper.SetConfigFile(cfgFile)
 	} else {
 		// Find home directory.
-		_, err := homedir.Dir()
+		userHome, err := userHomeDir()
 		if err != nil {
-			fmt.Println(err)
+			fmt.Fprintln(rootCmd.OutOrStdout(), err)
 			os.Exit(1)
 		}
-		// Search config in home directory with name ".hvm" (without extension).
-		viper.AddConfigPath("$HOME/.hvm")
+		// Search config in the hvm configuration directory with name "hvm" (without extension).
+		viper.AddConfigPath(HvmConfigDir(userHome))
 		viper.SetConfigName("hvm")
 	}
-    // Use any matching environment variables
-	viper.AutomaticEnv()
 	// Use config file if found
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		// Report on stderr so output such as hvm config get stays scriptable
+		fmt.Fprintln(rootCmd.ErrOrStderr(), "Using config file:", viper.ConfigFileUsed())
+	}
+	rotateLog()
+	configureHvm()
+}
+
+// configureHvm passes the settings the hvm package works from on to it, with
+// its logging going to the log file and its warnings to stderr
+func configureHvm() {
+	s := hvm.DefaultSettings()
+	if userHome, err := userHomeDir(); err == nil {
+		s.Home = HvmDataDir(userHome)
+		s.ConfigDir = HvmConfigDir(userHome)
+	}
+	s.CacheTTL = viper.GetDuration("cache_ttl")
+	s.DisableCheckpoint = viper.GetBool("disable_checkpoint")
+	s.DownloadTimeout = viper.GetDuration("download_timeout")
+	s.DownloadStallTimeout = viper.GetDuration("download_stall_timeout")
+	s.MetadataTimeout = viper.GetDuration("metadata_timeout")
+	s.MirrorToken = viper.GetString("mirror_token")
+	s.MirrorUsername = viper.GetString("mirror_username")
+	s.MirrorPassword = viper.GetString("mirror_password")
+	s.MirrorHeaders = viper.GetStringMapString("mirror_headers")
+	s.VersionSource = viper.GetString("version_source")
+	s.Offline = viper.GetBool("offline")
+	if bundle := viper.GetString("ca_bundle"); bundle != "" {
+		if expanded, err := homedir.Expand(bundle); err == nil {
+			bundle = expanded
+		}
+		s.CABundle = bundle
+	}
+	s.TLSPins = viper.GetStringSlice("tls_pins")
+	// Only ever taken from the flag so that it is never left on by a forgotten
+	// configuration file or environment variable
+	if insecure {
+		fmt.Fprintln(rootCmd.ErrOrStderr(), "WARNING: --insecure disables TLS certificate verification! Anyone between hvm and the release site can")
+		fmt.Fprintln(rootCmd.ErrOrStderr(), "WARNING: replace what is downloaded, checksums included. Use ca_bundle to trust a private CA instead.")
+		s.InsecureSkipVerify = true
+	}
+	if f := viper.GetString("log_format"); f != "text" && f != "json" {
+		fmt.Fprintln(rootCmd.ErrOrStderr(), fmt.Sprintf("Warning: unknown log_format %q; expected text or json, so logging as text", f))
+	}
+	// Without a data directory there is no hvm.log to write to, such as when
+	// the home directory is / and only --config is given, so the null logger
+	// is kept unless log_file names a file
+	if s.Home != "" || viper.GetString("log_file") != "" {
+		s.Logger = newLogger(&logFileWriter{path: LogFilePath(s.Home)})
+	}
+	s.Warnings = rootCmd.ErrOrStderr()
+	hvm.Configure(s)
+}
+
+// rotateLog rotates the log file once per run when it exceeds log_max_size
+// megabytes, keeping log_keep rotated files
+func rotateLog() {
+	userHome, err := userHomeDir()
+	if err != nil {
+		return
+	}
+	maxBytes := int64(viper.GetInt("log_max_size")) * 1024 * 1024
+	if err := RotateLog(LogFilePath(HvmDataDir(userHome)), maxBytes, viper.GetInt("log_keep")); err != nil {
+		// Logging is best effort, so a failure to rotate must not stop hvm
+		fmt.Fprintln(rootCmd.ErrOrStderr(), "Cannot rotate log file with error:", err)
 	}
 }
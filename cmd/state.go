@@ -0,0 +1,194 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StateFileName is the name of the file in the data directory which records the
+// version of each binary hvm last made active
+const StateFileName string = "state.yaml"
+
+// historyLimit is the number of previously active versions kept for each binary
+const historyLimit int = 10
+
+// State is what hvm records about its own actions in the state file
+type State struct {
+	// Active maps each binary to the version last linked into the user bin directory
+	Active map[string]string `yaml:"active"`
+	// History maps each binary to the versions which were active before the
+	// current one, most recently active last, for hvm rollback
+	History map[string][]string `yaml:"history,omitempty"`
+	// Global maps each binary to the version last linked into global_bin_dir
+	Global map[string]string `yaml:"global,omitempty"`
+}
+
+// statePath returns the path of the state file in the data directory hvmHome
+func statePath(hvmHome string) string {
+	return filepath.Join(hvmHome, StateFileName)
+}
+
+// ReadState reads the state file in the data directory hvmHome; a missing file
+// is an empty state, as for installations which predate the state file
+func ReadState(hvmHome string) (*State, error) {
+	s := &State{Active: map[string]string{}, History: map[string][]string{}, Global: map[string]string{}}
+	data, err := ioutil.ReadFile(statePath(hvmHome))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("Cannot read state file %s with error: %v", statePath(hvmHome), err)
+	}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("Cannot parse state file %s with error: %v", statePath(hvmHome), err)
+	}
+	if s.Active == nil {
+		s.Active = map[string]string{}
+	}
+	if s.History == nil {
+		s.History = map[string][]string{}
+	}
+	if s.Global == nil {
+		s.Global = map[string]string{}
+	}
+	return s, nil
+}
+
+// Write replaces the state file in the data directory hvmHome with s; the file is
+// renamed into place so that an interrupted write cannot leave it truncated
+func (s *State) Write(hvmHome string) error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(hvmHome, StateFileName+".*")
+	if err != nil {
+		return fmt.Errorf("Cannot write state file %s with error: %v", statePath(hvmHome), err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Cannot write state file %s with error: %v", statePath(hvmHome), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("Cannot write state file %s with error: %v", statePath(hvmHome), err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("Cannot write state file %s with error: %v", statePath(hvmHome), err)
+	}
+	return os.Rename(tmp.Name(), statePath(hvmHome))
+}
+
+// recordActiveVersion records v as the active version of binary b in the state
+// file in the data directory hvmHome, adding the version it replaces to the
+// history of b
+func recordActiveVersion(hvmHome string, b string, v string) error {
+	s, err := ReadState(hvmHome)
+	if err != nil {
+		return err
+	}
+	if s.Active[b] == v {
+		return nil
+	}
+	if previous := s.Active[b]; previous != "" {
+		history := append(s.History[b], previous)
+		if len(history) > historyLimit {
+			history = history[len(history)-historyLimit:]
+		}
+		s.History[b] = history
+	}
+	s.Active[b] = v
+	return s.Write(hvmHome)
+}
+
+// recordGlobalVersion records v as the version of binary b linked into
+// global_bin_dir in the state file in the data directory hvmHome; this is kept
+// apart from the active version, which rollback works from
+func recordGlobalVersion(hvmHome string, b string, v string) error {
+	s, err := ReadState(hvmHome)
+	if err != nil {
+		return err
+	}
+	if s.Global[b] == v {
+		return nil
+	}
+	s.Global[b] = v
+	return s.Write(hvmHome)
+}
+
+// ActiveVersion returns the active version of binary for reporting, or an empty
+// string if there is none. The state file is the source of truth, while the
+// symbolic link in the user bin directory is used for binaries the state file does
+// not know about yet; when the two disagree, as when the link was changed by hand,
+// a warning explaining how to reconcile them is written to warnings. A version
+// linked only into global_bin_dir is reported when there is no other.
+func ActiveVersion(binary string, warnings io.Writer) (string, error) {
+	userHome, err := userHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("Unable to determine user home directory; error: %v", err)
+	}
+	s, err := ReadState(HvmDataDir(userHome))
+	if err != nil {
+		return "", err
+	}
+	linked, err := SymlinkedVersion(binary)
+	if err != nil {
+		return "", err
+	}
+	recorded := s.Active[binary]
+	if recorded != "" {
+		// A recorded version which has since been removed is no longer active
+		if _, err := os.Stat(filepath.Join(HvmDataDir(userHome), binary, recorded)); os.IsNotExist(err) {
+			recorded = ""
+		}
+	}
+	if recorded == "" && linked == "" {
+		if global := s.Global[binary]; global != "" {
+			if _, err := os.Stat(filepath.Join(HvmDataDir(userHome), binary, global)); err == nil {
+				return global, nil
+			}
+		}
+	}
+	if recorded == "" {
+		return linked, nil
+	}
+	if linked != recorded {
+		linkPath := filepath.Join(userBinDir(userHome), binary)
+		if linked == "" {
+			fmt.Fprintln(warnings, fmt.Sprintf("Warning: %s version %s is recorded as active, but %s is not an hvm link to it; run hvm use %s --version %s to restore the link.", binary, recorded, linkPath, binary, recorded))
+		} else {
+			fmt.Fprintln(warnings, fmt.Sprintf("Warning: %s version %s is recorded as active, but %s points to version %s; run hvm use %s with the version you want to reconcile them.", binary, recorded, linkPath, linked, binary))
+		}
+	}
+	return recorded, nil
+}
@@ -44,7 +44,7 @@ hvm uninstall vault --version 0.7.2
 hvm uninstall nomad --version 0.6.5
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("uninstall called")
+		fmt.Fprintln(cmd.OutOrStdout(), "uninstall called")
 	},
 }
 
@@ -0,0 +1,301 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+	"github.com/hashicorp/go-getter"
+	"github.com/hashicorp/go-version"
+	"github.com/spf13/cobra"
+)
+
+// UpdateMeta contains data for an hvm self update candidate
+type UpdateMeta struct {
+	BinaryArch     string
+	BinaryOS       string
+	CurrentVersion string
+	LatestVersion  string
+	LogFile        string
+	UserHome       string
+	HvmHome        string
+}
+
+// GitHubRelease contains the parts of a GitHub release we care about
+type GitHubRelease struct {
+	TagName string               `json:"tag_name"`
+	Assets  []GitHubReleaseAsset `json:"assets"`
+}
+
+// GitHubReleaseAsset contains the parts of a GitHub release asset we care about
+type GitHubReleaseAsset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// updateCmd replaces the running hvm binary with the latest released version
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update hvm to the latest released version",
+	Long: `
+Check the GitHub releases for hvm and, if a version newer than the one
+currently running is available, download it and replace the running binary.
+
+The download is verified against the published SHA256SUMS for the release.
+On Windows, the running binary is renamed to hvm.exe.old to make way for the
+new one, and is removed by the next update.
+`,
+	Example: `
+  hvm update`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		m := UpdateMeta{}
+		userHome, err := userHomeDir()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot access home directory with error: %v", err))
+			os.Exit(1)
+		}
+		m.UserHome = userHome
+		m.HvmHome = HvmDataDir(m.UserHome)
+		m.LogFile = LogFilePath(m.HvmHome)
+		m.BinaryArch = runtime.GOARCH
+		m.BinaryOS = runtime.GOOS
+		m.CurrentVersion = hvmVersion
+		if _, err := os.Stat(m.HvmHome); os.IsNotExist(err) {
+			err = CreateHvmHome(m.HvmHome)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				os.Exit(1)
+			}
+		}
+		f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot open log file %s with error: %v", m.LogFile, err))
+			os.Exit(1)
+		}
+		defer f.Close()
+		logger := newLogger(f)
+		logger.Info("update", "run", "start", "current-version", m.CurrentVersion)
+
+		release, err := latestHvmRelease(cmd.Context())
+		if err != nil {
+			logger.Error("update", "latest-release-error", err.Error())
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine latest hvm release with error: %v", err))
+			os.Exit(exitCode(err))
+		}
+		m.LatestVersion = strings.TrimPrefix(release.TagName, "v")
+		currentVersion, err := version.NewVersion(m.CurrentVersion)
+		if err != nil {
+			logger.Error("update", "issue", "cannot determine current version", "error", err.Error())
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine current hvm version with error: %v", err))
+			os.Exit(1)
+		}
+		latestVersion, err := version.NewVersion(m.LatestVersion)
+		if err != nil {
+			logger.Error("update", "issue", "cannot determine latest version", "error", err.Error())
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine latest hvm version with error: %v", err))
+			os.Exit(1)
+		}
+		if !latestVersion.GreaterThan(currentVersion) {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("hvm version %s is already up to date.", m.CurrentVersion))
+			return
+		}
+		err = updateHvm(cmd.Context(), &m, release)
+		if err != nil {
+			logger.Error("update", "update-error", err.Error())
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot update hvm to version %s with error: %v", m.LatestVersion, err))
+			os.Exit(exitCode(err))
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Updated hvm from version %s to version %s", m.CurrentVersion, m.LatestVersion))
+	},
+}
+
+// Initialize the command
+func init() {
+	rootCmd.AddCommand(updateCmd)
+}
+
+// latestHvmRelease queries the GitHub releases API for the latest hvm release
+func latestHvmRelease(ctx context.Context) (*GitHubRelease, error) {
+	releaseURL := fmt.Sprintf("%s/repos/%s/releases/latest", hvm.GitHubAPIURLBase, HvmRepo)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, releaseURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "hvm-oss-http-client")
+	// Retried while GitHub is rate limiting, as with the release site
+	res, err := hvm.DoMetadataRequest(req)
+	if err != nil {
+		return nil, networkError(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, networkError(fmt.Errorf("unexpected response from GitHub: %s", res.Status))
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	release := GitHubRelease{}
+	err = json.Unmarshal(body, &release)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal JSON with error: %v", err)
+	}
+	return &release, nil
+}
+
+// updateHvm downloads the release archive for this host, verifies it against
+// the release SHA256SUMS and replaces the running executable with it
+func updateHvm(ctx context.Context, m *UpdateMeta, release *GitHubRelease) error {
+	v := m.LatestVersion
+	pkgFilename := fmt.Sprintf("hvm_%s_%s_%s.zip", v, m.BinaryOS, m.BinaryArch)
+	shaFilename := fmt.Sprintf("hvm_%s_SHA256SUMS", v)
+	var pkgURL, shaURL string
+	for _, a := range release.Assets {
+		switch a.Name {
+		case pkgFilename:
+			pkgURL = a.BrowserDownloadURL
+		case shaFilename:
+			shaURL = a.BrowserDownloadURL
+		}
+	}
+	if pkgURL == "" {
+		return fmt.Errorf("release %s has no archive for %s/%s", release.TagName, m.BinaryOS, m.BinaryArch)
+	}
+	if shaURL == "" {
+		return fmt.Errorf("release %s has no SHA256SUMS file", release.TagName)
+	}
+	shaData, err := hvm.FetchData(ctx, shaURL)
+	if err != nil {
+		return err
+	}
+	fileSha, err := hvm.ParseSHA256SUMS(shaData, "hvm", v)
+	if err != nil {
+		return err
+	}
+	checkSha := fileSha[pkgFilename]
+	if checkSha == "" {
+		return fmt.Errorf("no checksum for %s in %s", pkgFilename, shaFilename)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot locate running hvm executable with error: %v", err)
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return fmt.Errorf("cannot resolve running hvm executable with error: %v", err)
+	}
+	// Download next to the running executable so the final rename stays on
+	// the same filesystem
+	downloadPath := fmt.Sprintf("%s.update", executable)
+	fullURL := fmt.Sprintf("%s?checksum=sha256:%s", pkgURL, checkSha)
+	dctx, cancel := hvm.DownloadContext(ctx)
+	defer cancel()
+	if err := getter.GetFile(downloadPath, fullURL, getter.WithContext(dctx), hvm.WithHTTPGetter(nil)); err != nil {
+		os.Remove(downloadPath)
+		return updateDownloadError(err, v)
+	}
+	if err := os.Chmod(downloadPath, 0755); err != nil {
+		os.Remove(downloadPath)
+		return err
+	}
+	if err := replaceExecutable(executable, downloadPath); err != nil {
+		os.Remove(downloadPath)
+		return err
+	}
+	return nil
+}
+
+// updateDownloadError explains why downloading hvm version v failed, with the
+// exit code the failure calls for; only a failure which may go away by trying
+// again later is a network failure
+func updateDownloadError(err error, v string) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return networkError(fmt.Errorf("Download of hvm version %s timed out; run update again, or raise download_timeout", v))
+	}
+	var cerr *getter.ChecksumError
+	if errors.As(err, &cerr) {
+		return fmt.Errorf("the checksum of the downloaded hvm version %s does not match the published one, so it may be corrupt or tampered with", v)
+	}
+	switch code := hvm.ResponseCode(err); {
+	case code == http.StatusNotFound:
+		return &hvm.ExitError{Code: hvm.ExitInvalidVersion, Err: fmt.Errorf("hvm version %s is not published for %s/%s", v, runtime.GOOS, runtime.GOARCH)}
+	case code >= 500:
+		return networkError(fmt.Errorf("Cannot download hvm version %s; GitHub responded with code %d, so try again later", v, code))
+	case code != 0:
+		return fmt.Errorf("Cannot download hvm version %s; GitHub responded with code %d", v, code)
+	}
+	var uerr *url.Error
+	var nerr net.Error
+	if errors.As(err, &uerr) || errors.As(err, &nerr) {
+		return networkError(fmt.Errorf("Cannot download hvm version %s with error: %v", v, err))
+	}
+	return fmt.Errorf("Cannot download hvm version %s with error: %v", v, err)
+}
+
+// replaceExecutable puts the downloaded hvm at downloadPath in place of the
+// running executable. Windows does not allow replacing the file of a running
+// program, but does allow renaming it, so there the running executable is moved
+// aside to executable.old first, and moved back should the replacement fail;
+// the old file is removed by the next hvm update once nothing runs it.
+func replaceExecutable(executable string, downloadPath string) error {
+	if runtime.GOOS != "windows" {
+		if err := os.Rename(downloadPath, executable); err != nil {
+			return fmt.Errorf("cannot replace %s with error: %v", executable, err)
+		}
+		return nil
+	}
+	oldPath := fmt.Sprintf("%s.old", executable)
+	if err := os.Remove(oldPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove %s left by an earlier update with error: %v", oldPath, err)
+	}
+	if err := os.Rename(executable, oldPath); err != nil {
+		return fmt.Errorf("cannot move %s aside to %s with error: %v", executable, oldPath, err)
+	}
+	if err := os.Rename(downloadPath, executable); err != nil {
+		if rerr := os.Rename(oldPath, executable); rerr != nil {
+			return fmt.Errorf("cannot replace %s with error: %v, nor move it back from %s with error: %v", executable, err, oldPath, rerr)
+		}
+		return fmt.Errorf("cannot replace %s with error: %v", executable, err)
+	}
+	// Removing fails while this process still runs the old file, which the
+	// next update takes care of
+	os.Remove(oldPath)
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+)
+
+// UpgradeMeta contains data for upgrading binaries to their latest versions
+type UpgradeMeta struct {
+	BinaryNames []string
+	DryRun      bool
+	LogFile     string
+	Out         io.Writer
+	Err         io.Writer
+	UserHome    string
+	HvmHome     string
+}
+
+var upgradeDryRun bool
+
+// upgradeCmd installs and uses the latest version of binaries
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade [<binary>...]",
+	Short: "Install and use the latest version of binaries",
+	Long: `
+Install the latest version of the specified binaries, or of every binary
+with a version in use if none are specified, and use it. Binaries already
+using the latest version are skipped.
+`,
+	Example: `
+  hvm upgrade
+
+  hvm upgrade terraform vault
+
+  hvm upgrade --dry-run`,
+	ValidArgs: hvm.SupportedBinaries(),
+	Args: func(cmd *cobra.Command, args []string) error {
+		for _, b := range args {
+			if !hvm.IsSupported(b) {
+				return unsupportedError(fmt.Errorf("Cannot upgrade %q; it is not a supported binary; for a list of supported binaries, use hvm upgrade --help", b))
+			}
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		m := UpgradeMeta{Out: cmd.OutOrStdout(), Err: cmd.ErrOrStderr()}
+		userHome, err := userHomeDir()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot access home directory with error: %v", err))
+			os.Exit(1)
+		}
+		m.UserHome = userHome
+		m.HvmHome = HvmDataDir(m.UserHome)
+		m.LogFile = LogFilePath(m.HvmHome)
+		m.DryRun = upgradeDryRun
+		m.BinaryNames = args
+		named := len(m.BinaryNames) > 0
+		if !named {
+			m.BinaryNames = hvm.SupportedBinaries()
+		}
+		if _, err := os.Stat(m.HvmHome); os.IsNotExist(err) {
+			err = CreateHvmHome(m.HvmHome)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				os.Exit(1)
+			}
+		}
+		f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot open log file %s with error: %v", m.LogFile, err))
+			os.Exit(1)
+		}
+		defer f.Close()
+		logger := newLogger(f)
+
+		code := hvm.ExitOK
+		summary := []string{"Binary | From | To | Status"}
+		for _, b := range m.BinaryNames {
+			current, err := SymlinkedVersion(b)
+			if err != nil {
+				logger.Error("upgrade", "binary", b, "error", err.Error())
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine active %s version with error: %v", b, err))
+				os.Exit(1)
+			}
+			// Only binaries named explicitly are upgraded when none is in use
+			if current == "" && !named {
+				continue
+			}
+			from := current
+			if from == "" {
+				from = "none"
+			}
+			latest, err := hvm.GetLatestVersion(cmd.Context(), b)
+			if err != nil {
+				logger.Error("upgrade", "binary", b, "error", err.Error())
+				summary = append(summary, fmt.Sprintf("%s | %s | unknown | failed: %v", b, from, err))
+				code = exitCode(err)
+				continue
+			}
+			if current == latest {
+				summary = append(summary, fmt.Sprintf("%s | %s | %s | up to date", b, from, latest))
+				continue
+			}
+			logger.Info("upgrade", "binary", b, "from", from, "to", latest, "dry-run", m.DryRun)
+			if m.DryRun {
+				summary = append(summary, fmt.Sprintf("%s | %s | %s | would upgrade", b, from, latest))
+				continue
+			}
+			if err := upgradeBinary(cmd.Context(), &m, b, latest); err != nil {
+				logger.Error("upgrade", "binary", b, "version", latest, "error", err.Error())
+				summary = append(summary, fmt.Sprintf("%s | %s | %s | failed: %v", b, from, latest, err))
+				code = exitCode(err)
+				continue
+			}
+			summary = append(summary, fmt.Sprintf("%s | %s | %s | upgraded", b, from, latest))
+		}
+		if len(summary) == 1 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No binary versions are currently in use.")
+			return
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), columnize.SimpleFormat(summary))
+		os.Exit(code)
+	},
+}
+
+// Initialize the command
+func init() {
+	rootCmd.AddCommand(upgradeCmd)
+	upgradeCmd.PersistentFlags().BoolVar(&upgradeDryRun,
+		"dry-run",
+		false,
+		"print what would be upgraded without downloading anything")
+}
+
+// upgradeBinary installs version v of binary b unless it is already installed
+// and then uses it
+func upgradeBinary(ctx context.Context, m *UpgradeMeta, b string, v string) error {
+	installed, err := hvm.IsInstalledVersion(b, v)
+	if err != nil {
+		return err
+	}
+	if !installed {
+		im := InstallMeta{
+			BinaryName:           b,
+			BinaryDesiredVersion: v,
+			BinaryOS:             runtime.GOOS,
+			BinaryArch:           runtime.GOARCH,
+			LogFile:              m.LogFile,
+			Out:                  m.Out,
+			Err:                  m.Err,
+			UserHome:             m.UserHome,
+			HvmHome:              m.HvmHome,
+		}
+		if err := installBinary(ctx, &im); err != nil {
+			return err
+		}
+	}
+	return linkBinary(m.UserHome, m.HvmHome, b, v)
+}
@@ -0,0 +1,129 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+)
+
+var (
+	urlVersion string
+	urlOS      string
+	urlArch    string
+)
+
+// urlCmd prints the URLs install would download a binary version from
+var urlCmd = &cobra.Command{
+	Use:   "url (<binary>) [--version <version>]",
+	Short: "Print the download URLs of a binary version",
+	Long: `
+Print the SHA256SUMS URL and the fully constructed download URL, including
+its checksum query, that hvm install uses for a binary version, without
+downloading the archive; this is handy for reproducing a failed install
+against a mirror with curl. If the version flag is omitted, the latest
+available version is used.
+`,
+	Example: `
+  hvm url vault --version 1.0.2
+
+  hvm url terraform --version 0.11.11 --os linux --arch amd64`,
+	ValidArgs: hvm.SupportedBinaries(),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("requires exactly one argument, the name of a binary.")
+		}
+		if !hvm.IsSupported(args[0]) {
+			return unsupportedError(fmt.Errorf("Cannot print URLs for %q; it is not a supported binary; for a list of supported binaries, use hvm install --help", args[0]))
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		b := args[0]
+		v := urlVersion
+		if v == "" {
+			v = VersionFromEnv(b)
+		}
+		if v == "" {
+			v = DefaultVersion(b)
+		}
+		if v != "" {
+			if err := hvm.CheckVersionFormat(v); err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot print URLs for %s with error: %v", b, err))
+				os.Exit(hvm.ExitInvalidVersion)
+			}
+		}
+		urlArch = hvm.NormalizeArch(urlArch)
+		if err := hvm.ValidateBinaryPlatform(b, urlOS, urlArch); err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot print URLs for %s with error: %v", b, err))
+			os.Exit(hvm.ExitUnsupported)
+		}
+		if v == "" {
+			latestVersion, err := hvm.GetLatestVersion(cmd.Context(), b)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine latest %s version with error: %v", b, err))
+				os.Exit(exitCode(err))
+			}
+			v = latestVersion
+		}
+		urls, err := hvm.ReleaseDownloadURLs(cmd.Context(), b, v, urlOS, urlArch)
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine URLs for %s version %s with error: %v", b, v, err))
+			os.Exit(exitCode(err))
+		}
+		if urls.Checksum == "" {
+			fmt.Fprintln(cmd.ErrOrStderr(), fmt.Sprintf("Warning: %s is not listed in %s", urls.Archive, urls.SHA256SUMS))
+		}
+		out := []string{
+			fmt.Sprintf("SHA256SUMS: | %s", urls.SHA256SUMS),
+			fmt.Sprintf("Download URL: | %s", urls.URL),
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), columnize.SimpleFormat(out))
+	},
+}
+
+// Initialize the command
+func init() {
+	rootCmd.AddCommand(urlCmd)
+	urlCmd.PersistentFlags().StringVar(&urlVersion,
+		"version",
+		"",
+		"binary version (default is HVM_<BINARY>_VERSION, the defaults setting, or the latest)")
+	urlCmd.PersistentFlags().StringVar(&urlOS,
+		"os",
+		runtime.GOOS,
+		"binary operating system")
+	urlCmd.PersistentFlags().StringVar(&urlArch,
+		"arch",
+		runtime.GOARCH,
+		"binary architecture; aliases such as x86_64 or aarch64 are accepted")
+}
@@ -26,98 +26,232 @@
 package cmd
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
 
-	"github.com/hashicorp/go-hclog"
-	"github.com/mitchellh/go-homedir"
+	"github.com/brianshumate/hvm/pkg/hvm"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // UseMeta contains data for using a binary version
 type UseMeta struct {
+	Backup               bool
 	BinaryArch           string
 	BinaryName           string
+	BinName              string
 	BinaryOS             string
 	BinaryDesiredVersion string
+	FromFile             string
+	Global               bool
+	IncludePrerelease    bool
+	Install              bool
+	Latest               bool
 	LogFile              string
+	Out                  io.Writer
+	Err                  io.Writer
 	UserHome             string
 	HvmHome              string
 }
 
+var (
+	useLatest            bool
+	useGlobal            bool
+	useInstall           bool
+	useIncludePrerelease bool
+	useFromFile          string
+	useBinName           string
+	useBackup            bool
+)
+
 // useCmd represents the use command
 var useCmd = &cobra.Command{
-	Use:   "use (<binary>) [--version <version>]",
+	Use:   "use (<binary>) [--version <version> | --latest] | --from-file <hvm.yaml>",
 	Short: "Use a specific binary version",
 	Long: `
 Use a supported binary binary at specified version.
-The --version flag is required.
+The --version flag is required unless --latest is used to select the newest
+locally installed version, or the version is given by an HVM_<BINARY>_VERSION
+environment variable such as HVM_TERRAFORM_VERSION, declared for the project
+by an hvm.yaml, .terraform-version or .tool-versions file, or set for the
+binary by the defaults setting. With --install, a
+version which is not yet installed is installed first.
+
+With --from-file and no binary, every tool version declared in an hvm.yaml
+project file is used at once: all of them must be installed before any link
+is changed, and should one link fail, those already changed are restored.
+
+With --bin-name, the link is given another name, such as terraform-1.0, so
+that several versions of a binary can be on PATH at once; such a link does
+not change which version of the binary is active.
+
+A file in the way of the link which hvm did not create, such as a copy of
+the binary, is refused unless --backup is given to rename it to
+<binary>.hvm-backup first.
 
 hvm can use the following binaries:
 
 * consul
+* consul-k8s
 * consul-template (WIP)
 * envconsul (WIP)
 * nomad
+* nomad-autoscaler
 * packer
 * sentinel (WIP)
+* serf
 * terraform
+* terraform-ls
 * vagrant
 * vault`,
 	Example: `
   hvm use --help
 
-  hvm use vault --version 1.0.2`,
-	ValidArgs: []string{"consul",
-		"consul-template",
-		"envconsul",
-		"nomad",
-		"packer",
-		"sentinel",
-		"terraform",
-		"vagrant",
-		"vault"},
-	Args: cobra.MinimumNArgs(1),
+  hvm use vault --version 1.0.2
+
+  hvm use terraform --latest
+
+  hvm use vault --version 1.0.2 --install
+
+  hvm use terraform --version 1.0.11 --bin-name terraform-1.0
+
+  hvm use terraform --version 1.0.11 --backup
+
+  hvm use --from-file hvm.yaml
+
+  sudo hvm use terraform --version 0.11.11 --global`,
+	ValidArgs: hvm.SupportedBinaries(),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			if useFromFile != "" {
+				return nil
+			}
+			return errors.New("requires at least one argument, the name of a binary to use.")
+		}
+		if !hvm.IsSupported(args[0]) {
+			return unsupportedError(fmt.Errorf("Cannot use %q; it is not a supported binary; for a list of supported binaries, use hvm use --help", args[0]))
+		}
+		return nil
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		m := UseMeta{}
-		userHome, err := homedir.Dir()
+		m := UseMeta{Out: cmd.OutOrStdout(), Err: cmd.ErrOrStderr()}
+		userHome, err := userHomeDir()
 		if err != nil {
-			fmt.Println(fmt.Sprintf("Cannot access home directory with error: %v", err))
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot access home directory with error: %v", err))
 			os.Exit(1)
 		}
 		m.UserHome = userHome
-		m.HvmHome = fmt.Sprintf("%s/.hvm", m.UserHome)
-		m.LogFile = fmt.Sprintf("%s/hvm.log", m.HvmHome)
+		m.HvmHome = HvmDataDir(m.UserHome)
+		m.LogFile = LogFilePath(m.HvmHome)
 		m.BinaryArch = runtime.GOARCH
 		m.BinaryDesiredVersion = binaryVersion
 		m.BinaryOS = runtime.GOOS
 		m.BinaryName = strings.Join(args, " ")
+		m.Latest = useLatest
+		m.Global = useGlobal
+		m.Install = useInstall
+		m.IncludePrerelease = useIncludePrerelease
+		m.FromFile = useFromFile
+		m.BinName = useBinName
+		m.Backup = useBackup
+		if m.FromFile != "" {
+			if len(args) > 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot use %s with --from-file, which uses every tool the file declares.", m.BinaryName))
+				os.Exit(1)
+			}
+			for _, name := range []string{"version", "latest", "install", "include-prerelease", "global", "bin-name", "backup"} {
+				if cmd.Flags().Changed(name) {
+					fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot use --%s with --from-file.", name))
+					os.Exit(1)
+				}
+			}
+			p, err := ReadProjectFile(m.FromFile)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				os.Exit(exitCode(err))
+			}
+			if err := useSet(m.UserHome, m.HvmHome, p.Tools); err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				os.Exit(exitCode(err))
+			}
+			for _, b := range p.Binaries() {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Using %s version %s", b, p.Tools[b]))
+			}
+			return
+		}
 		b := m.BinaryName
+		if m.IncludePrerelease && !m.Install {
+			fmt.Fprintln(cmd.OutOrStdout(), "Cannot use --include-prerelease without --install.")
+			os.Exit(1)
+		}
+		if cmd.Flags().Changed("bin-name") {
+			if err := checkBinName(m.BinName); err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot use %s with error: %v", b, err))
+				os.Exit(1)
+			}
+		}
+		if m.Latest {
+			if m.BinaryDesiredVersion != "" {
+				fmt.Fprintln(cmd.OutOrStdout(), "Cannot use both --version and --latest.")
+				os.Exit(1)
+			}
+			if m.Install {
+				fmt.Fprintln(cmd.OutOrStdout(), "Cannot use --install with --latest, which selects an installed version.")
+				os.Exit(1)
+			}
+			localVersions, err := hvm.ListLocalVersions(b)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine installed %s versions with error: %v", b, err))
+				os.Exit(1)
+			}
+			if len(localVersions) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("No %s versions are installed; install one with: hvm install %s", b, b))
+				os.Exit(1)
+			}
+			m.BinaryDesiredVersion = localVersions[len(localVersions)-1]
+		} else if m.BinaryDesiredVersion == "" {
+			m.BinaryDesiredVersion = VersionFromEnv(b)
+			if m.BinaryDesiredVersion == "" {
+				v, err := ProjectVersion(b)
+				if err != nil {
+					fmt.Fprintln(cmd.OutOrStdout(), err)
+					os.Exit(exitCode(err))
+				}
+				m.BinaryDesiredVersion = v
+			}
+			if m.BinaryDesiredVersion == "" {
+				m.BinaryDesiredVersion = DefaultVersion(b)
+			}
+		}
 		v := m.BinaryDesiredVersion
 		if _, err := os.Stat(m.HvmHome); os.IsNotExist(err) {
-			err = os.Mkdir(m.HvmHome, 0755)
+			err = CreateHvmHome(m.HvmHome)
 			if err != nil {
-			fmt.Println(fmt.Sprintf("Cannot create directory %s with error: %v", m.HvmHome, err))
+			fmt.Fprintln(cmd.OutOrStdout(), err)
 			os.Exit(1)
 			}
 		}
 		f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 		if err != nil {
-			fmt.Println(fmt.Sprintf("Cannot open log file %s with error: %v", m.LogFile, err))
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot open log file %s with error: %v", m.LogFile, err))
 			os.Exit(1)
 		}
 		defer f.Close()
-		w := bufio.NewWriter(f)
-		logger := hclog.New(&hclog.LoggerOptions{Name: "hvm", Level: hclog.LevelFromString("INFO"), Output: w})
+		logger := newLogger(f)
 		logger.Info("use", "run", "start with binary", b, "desired version", v)
 
-		err = useBinary(&m)
+		err = useBinary(cmd.Context(), &m)
 		if err != nil {
-			fmt.Println(fmt.Sprintf("Cannot use binary %s with error: %v", b, err))
-			os.Exit(1)
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot use binary %s with error: %v", b, err))
+			os.Exit(exitCode(err))
 		}
 	},
 }
@@ -128,11 +262,39 @@ func init() {
 	useCmd.PersistentFlags().StringVar(&binaryVersion,
 		"version",
 		"",
-		"use binary version")
-	useCmd.MarkFlagRequired("version")
+		"use binary version (default is HVM_<BINARY>_VERSION, the project version, or the defaults setting)")
+	useCmd.PersistentFlags().BoolVar(&useLatest,
+		"latest",
+		false,
+		"use the newest installed binary version")
+	useCmd.PersistentFlags().BoolVarP(&useInstall,
+		"install",
+		"i",
+		false,
+		"install the binary version first if it is not installed")
+	useCmd.PersistentFlags().BoolVar(&useIncludePrerelease,
+		"include-prerelease",
+		false,
+		"with --install, allow installing prerelease versions such as betas and release candidates")
+	useCmd.PersistentFlags().StringVar(&useFromFile,
+		"from-file",
+		"",
+		"use every tool version declared in this hvm.yaml project file together")
+	useCmd.PersistentFlags().BoolVar(&useGlobal,
+		"global",
+		false,
+		"link the binary into global_bin_dir for all users instead of ~/bin")
+	useCmd.PersistentFlags().StringVar(&useBinName,
+		"bin-name",
+		"",
+		"name the link this instead of the binary name, leaving the active version unchanged")
+	useCmd.PersistentFlags().BoolVar(&useBackup,
+		"backup",
+		false,
+		"rename a file which is not an hvm link out of the way of the link rather than refusing")
 }
 
-func useBinary(m *UseMeta) error {
+func useBinary(ctx context.Context, m *UseMeta) error {
 	b := m.BinaryName
 	v := m.BinaryDesiredVersion
 	f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
@@ -140,8 +302,7 @@ func useBinary(m *UseMeta) error {
 		return fmt.Errorf("Cannot open log file with error: %v", err)
 	}
 	defer f.Close()
-	w := bufio.NewWriter(f)
-	logger := hclog.New(&hclog.LoggerOptions{Name: "hvm", Level: hclog.LevelFromString("INFO"), Output: w})
+	logger := newLogger(f)
 	logger.Debug("use", "f-use-binary", b)
 	if m.BinaryName == "" {
 		m.BinaryName = "none"
@@ -150,37 +311,346 @@ func useBinary(m *UseMeta) error {
 	}
 	if m.BinaryDesiredVersion == "" {
 		logger.Debug("use", "f-use-binary", b)
-		return fmt.Errorf("Unknown binary version; please specify version with '--version' flag")
+		return fmt.Errorf("Unknown binary version; please specify version with '--version' flag, HVM_<BINARY>_VERSION or the defaults setting")
 	}
 	logger.Info("use", "binary", b, "desired-version", v)
+	if err := hvm.CheckVersionFormat(v); err != nil {
+		return &hvm.ExitError{Code: hvm.ExitInvalidVersion, Err: err}
+	}
 
-	// Is desired binary version valid?
-	vv, err := ValidVersion(b, v)
-	if err != nil {
-		fmt.Println(fmt.Sprintf("Cannot determine if %s version %s is valid: %v", b, v, err))
-		os.Exit(1)
-	} else {
-		if vv == false {
-			fmt.Println(fmt.Sprintf("%s is not a version of %s hvm can use", v, b))
-			os.Exit(1)
+	// Is desired binary version valid? The newest installed version was
+	// already found locally, so there is no need to check it remotely, and
+	// offline only the local installation below is checked.
+	if !m.Latest && !viper.GetBool("offline") {
+		vv, knownVersions, err := hvm.ValidateVersion(ctx, b, v)
+		if err != nil {
+			return fmt.Errorf("Cannot determine if %s version %s is valid: %w", b, v, err)
+		} else {
+			if vv == false {
+				return &hvm.ExitError{Code: hvm.ExitInvalidVersion, Err: fmt.Errorf("%s is not a version of %s hvm can use.%s", v, b, didYouMean(hvm.SuggestVersions(v, knownVersions)))}
+			}
 		}
 	}
 
 	// Is desired binary already installed?
 	var installedVersion bool
-	installedVersion, err = InstalledVersion(b, v)
+	installedVersion, err = hvm.IsInstalledVersion(b, v)
 	if err != nil {
-		fmt.Println(fmt.Sprintf("Cannot determine if %s version %s is installed: %v", b, v, err))
-		os.Exit(1)
+		return fmt.Errorf("Cannot determine if %s version %s is installed: %v", b, v, err)
 	}
 	if installedVersion == true {
 		logger.Debug("use", "binary", b, "version", v, "installed", "true")
+	} else if m.Install {
+		if !m.IncludePrerelease {
+			// The same gate as hvm install, which --install must not get around
+			if err := checkPrerelease(b, v); err != nil {
+				logger.Error("use", "f-use-binary", "install", "error", err)
+				return err
+			}
+		}
+		logger.Info("use", "binary", b, "version", v, "installed", "false", "installing", "true")
+		im := InstallMeta{
+			BinaryName:           b,
+			BinaryDesiredVersion: v,
+			BinaryOS:             m.BinaryOS,
+			BinaryArch:           m.BinaryArch,
+			Global:               m.Global,
+			LogFile:              m.LogFile,
+			Out:                  m.Out,
+			Err:                  m.Err,
+			UserHome:             m.UserHome,
+			HvmHome:              m.HvmHome,
+		}
+		if err := installBinary(ctx, &im); err != nil {
+			logger.Error("use", "f-use-binary", "install", "error", err)
+			return err
+		}
 	} else {
-		fmt.Println(fmt.Sprintf("%s version %s is not installed; install it with: hvm install %s --version %s", b, v, b, v))
-		os.Exit(1)
+		return fmt.Errorf("%s version %s is not installed; install it with: hvm install %s --version %s, or use --install", b, v, b, v)
+	}
+	binDir := userBinDir(m.UserHome)
+	if m.Global {
+		binDir = viper.GetString("global_bin_dir")
+	}
+	var linkPath, backupPath string
+	if m.Backup {
+		name := b
+		if m.BinName != "" {
+			name = m.BinName
+		}
+		linkPath = fmt.Sprintf("%s/%s", binDir, name)
+		backupPath, err = backupBinPath(linkPath)
+		if err != nil {
+			logger.Error("use", "f-use-binary", "backup", linkPath, "error", err)
+			return err
+		}
+		if backupPath != "" {
+			logger.Info("use", "binary", b, "backup", linkPath, "to", backupPath)
+			fmt.Fprintln(m.Out, fmt.Sprintf("Moved %s, which is not an hvm link, to %s", linkPath, backupPath))
+		}
+	}
+	// Should the link fail, what was moved out of its way is put back
+	restore := func() {
+		if backupPath == "" {
+			return
+		}
+		if err := restoreBackup(linkPath, backupPath); err != nil {
+			logger.Error("use", "f-use-binary", "restore", backupPath, "error", err)
+			fmt.Fprintln(m.Err, fmt.Sprintf("Warning: cannot move %s back to %s with error: %v", backupPath, linkPath, err))
+			return
+		}
+		logger.Info("use", "binary", b, "restored", backupPath, "to", linkPath)
+		fmt.Fprintln(m.Out, fmt.Sprintf("Moved %s back to %s", backupPath, linkPath))
+	}
+	if m.BinName != "" && m.BinName != b {
+		// A differently named link sits alongside the active version, so the
+		// state is left alone
+		if err := linkBinaryAs(binDir, m.HvmHome, b, v, m.BinName); err != nil {
+			logger.Error("use", "f-use-binary", "symlink", "name", m.BinName, "error", err)
+			restore()
+			return err
+		}
+		fmt.Fprintln(m.Out, fmt.Sprintf("Using %s (%s/%s) version %s as %s", b, m.BinaryOS, m.BinaryArch, v, m.BinName))
+		warnShadowed(m.Err, binDir, m.BinName)
+		return nil
+	}
+	if m.Global {
+		err = linkBinaryGlobal(m.HvmHome, b, v)
+	} else {
+		err = linkBinary(m.UserHome, m.HvmHome, b, v)
+	}
+	if err != nil {
+		logger.Error("use", "f-use-binary", "symlink", "error", err)
+		restore()
+		return err
+	}
+	fmt.Fprintln(m.Out, fmt.Sprintf("Using %s (%s/%s) version %s", b, m.BinaryOS, m.BinaryArch, v))
+	warnShadowed(m.Err, binDir, b)
+	return nil
+}
+
+// warnShadowed warns when running binary b from PATH would not run the hvm
+// managed link in binDir, which otherwise looks like switching versions did not
+// work, writing the warning to w
+func warnShadowed(w io.Writer, binDir string, b string) {
+	shadow, err := ShadowingPath(binDir, b)
+	if err != nil {
+		fmt.Fprintln(w, fmt.Sprintf("Warning: %v", err))
+		return
+	}
+	if shadow != "" {
+		fmt.Fprintln(w, fmt.Sprintf("Warning: %s is found first on PATH at %s, which shadows the version used by hvm", b, shadow))
+	}
+}
+
+// userBinDir returns the user bin directory hvm links binaries into
+func userBinDir(userHome string) string {
+	return filepath.Join(userHome, "bin")
+}
+
+// useSet points the links in the user bin directory of every binary in versions
+// at its version as a single change: every version must be installed before any
+// link is touched, and should linking one of them fail, the links already
+// changed and the state file are restored to how they were
+func useSet(userHome string, hvmHome string, versions map[string]string) error {
+	binaries := make([]string, 0, len(versions))
+	for b := range versions {
+		binaries = append(binaries, b)
+	}
+	sort.Strings(binaries)
+	for _, b := range binaries {
+		installed, err := hvm.IsInstalledVersion(b, versions[b])
+		if err != nil {
+			return fmt.Errorf("Cannot determine if %s version %s is installed: %v", b, versions[b], err)
+		}
+		if !installed {
+			return fmt.Errorf("%s version %s is not installed; install it with: hvm install %s --version %s", b, versions[b], b, versions[b])
+		}
+	}
+	state, err := ReadState(hvmHome)
+	if err != nil {
+		return err
+	}
+	// The previous target of each link touched so far, empty if it had none
+	previous := map[string]string{}
+	changed := []string{}
+	for _, b := range binaries {
+		target, err := linkTarget(fmt.Sprintf("%s/%s", userBinDir(userHome), b))
+		if err != nil {
+			return fmt.Errorf("Cannot use %s version %s; error: %v", b, versions[b], err)
+		}
+		// The link for b is recorded before linking, as linkBinary can
+		// replace it and still fail when writing the state file
+		previous[b] = target
+		changed = append(changed, b)
+		if err := linkBinary(userHome, hvmHome, b, versions[b]); err != nil {
+			restoreErrs := restoreLinks(userBinDir(userHome), changed, previous)
+			if err := state.Write(hvmHome); err != nil {
+				restoreErrs = append(restoreErrs, fmt.Sprintf("cannot restore the state file with error: %v", err))
+			}
+			if len(restoreErrs) > 0 {
+				return fmt.Errorf("Cannot use %s version %s, and some changes could not be undone; please check these by hand: %s; error: %v", b, versions[b], strings.Join(restoreErrs, "; "), err)
+			}
+			return fmt.Errorf("Cannot use %s version %s, so no versions were changed; error: %v", b, versions[b], err)
+		}
+	}
+	return nil
+}
+
+// linkTarget returns the target of the symbolic link at linkPath, or an empty
+// string when nothing is there; anything other than a symbolic link is left
+// for linkBinary to refuse
+func linkTarget(linkPath string) (string, error) {
+	fi, err := os.Lstat(linkPath)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Cannot inspect %s with error: %v", linkPath, err)
+	}
+	if fi.Mode()&os.ModeSymlink != os.ModeSymlink {
+		return "", nil
+	}
+	target, err := os.Readlink(linkPath)
+	if err != nil {
+		return "", fmt.Errorf("Cannot read the link %s with error: %v", linkPath, err)
+	}
+	return target, nil
+}
+
+// restoreLinks puts the symbolic link in binDir for each of binaries back to
+// its previous target, removing it when it had none, and returns a
+// description of every link that could not be put back
+func restoreLinks(binDir string, binaries []string, previous map[string]string) []string {
+	errs := []string{}
+	for _, b := range binaries {
+		linkPath := fmt.Sprintf("%s/%s", binDir, b)
+		// Only a link can have been made here, so anything else is left alone
+		if fi, err := os.Lstat(linkPath); err == nil && fi.Mode()&os.ModeSymlink == os.ModeSymlink {
+			if err := os.Remove(linkPath); err != nil {
+				errs = append(errs, fmt.Sprintf("cannot remove %s with error: %v", linkPath, err))
+				continue
+			}
+		}
+		if previous[b] == "" {
+			continue
+		}
+		if err := os.Symlink(previous[b], linkPath); err != nil {
+			errs = append(errs, fmt.Sprintf("cannot point %s back at %s with error: %v", linkPath, previous[b], err))
+		}
+	}
+	return errs
+}
+
+// linkBinary points the symbolic link for binary b in the user bin directory
+// at the hvm installed version v and records v as active in the state file
+func linkBinary(userHome string, hvmHome string, b string, v string) error {
+	if err := linkBinaryIn(userBinDir(userHome), hvmHome, b, v); err != nil {
+		return err
+	}
+	return recordActiveVersion(hvmHome, b, v)
+}
+
+// linkBinaryIn points the symbolic link for binary b in binDir at the hvm
+// installed version v
+func linkBinaryIn(binDir string, hvmHome string, b string, v string) error {
+	return linkBinaryAs(binDir, hvmHome, b, v, b)
+}
+
+// linkBinaryGlobal points the symbolic link for binary b in global_bin_dir at
+// the hvm installed version v, once other users can reach it, and records v as
+// the global version in the state file
+func linkBinaryGlobal(hvmHome string, b string, v string) error {
+	if err := shareVersion(hvmHome, b, v); err != nil {
+		return err
+	}
+	if err := linkBinaryIn(viper.GetString("global_bin_dir"), hvmHome, b, v); err != nil {
+		return err
 	}
-	srcPath := fmt.Sprintf("%s/%s/%s/%s", m.HvmHome, b, v, b)
-	destPath := fmt.Sprintf("%s/bin/%s", m.UserHome, b)
+	return recordGlobalVersion(hvmHome, b, v)
+}
+
+// shareVersion lets every user run the hvm installed version v of binary b, as
+// a link in global_bin_dir needs: the data directory hvmHome and the directories
+// below it down to the binary are made readable and searchable by all, while
+// the directories above hvmHome are only checked, as hvm does not own them
+func shareVersion(hvmHome string, b string, v string) error {
+	binPath := filepath.Join(hvmHome, b, v, b)
+	for _, p := range []string{hvmHome, filepath.Join(hvmHome, b), filepath.Join(hvmHome, b, v), binPath} {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return fmt.Errorf("Cannot inspect %s with error: %v", p, err)
+		}
+		if fi.Mode().Perm()&0005 == 0005 {
+			continue
+		}
+		if err := os.Chmod(p, fi.Mode().Perm()|0055); err != nil {
+			return fmt.Errorf("Cannot make %s readable by other users for a global link with error: %v", p, err)
+		}
+	}
+	for p := filepath.Dir(hvmHome); ; p = filepath.Dir(p) {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return fmt.Errorf("Cannot inspect %s with error: %v", p, err)
+		}
+		if fi.Mode().Perm()&0001 == 0 {
+			return fmt.Errorf("Cannot link %s into global_bin_dir, as other users cannot reach %s through %s; use a data directory other users can reach, such as with XDG_DATA_HOME=/opt", b, binPath, p)
+		}
+		if p == filepath.Dir(p) {
+			break
+		}
+	}
+	return nil
+}
+
+// backupBinPath renames whatever is at linkPath other than a symbolic link to
+// linkPath.hvm-backup, or to a name with the time added should that exist
+// already, and returns the new path; nothing is done and an empty path is
+// returned when linkPath does not exist or is a symbolic link
+func backupBinPath(linkPath string) (string, error) {
+	fi, err := os.Lstat(linkPath)
+	if os.IsNotExist(err) || (err == nil && fi.Mode()&os.ModeSymlink == os.ModeSymlink) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Cannot inspect %s with error: %v", linkPath, err)
+	}
+	backupPath := fmt.Sprintf("%s.hvm-backup", linkPath)
+	if _, err := os.Lstat(backupPath); err == nil {
+		backupPath = fmt.Sprintf("%s.hvm-backup-%s", linkPath, time.Now().Format("20060102T150405"))
+	}
+	if err := os.Rename(linkPath, backupPath); err != nil {
+		return "", fmt.Errorf("Cannot move %s to %s with error: %v", linkPath, backupPath, err)
+	}
+	return backupPath, nil
+}
+
+// restoreBackup moves the file which backupBinPath moved from linkPath to
+// backupPath back, replacing any link left at linkPath by the failed attempt
+// to make one
+func restoreBackup(linkPath string, backupPath string) error {
+	if fi, err := os.Lstat(linkPath); err == nil && fi.Mode()&os.ModeSymlink == os.ModeSymlink {
+		if err := os.Remove(linkPath); err != nil {
+			return err
+		}
+	}
+	return os.Rename(backupPath, linkPath)
+}
+
+// checkBinName returns an error unless name can be used as the file name of a
+// link in a bin directory
+func checkBinName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("--bin-name %q is not a file name; give a name such as terraform-1.0", name)
+	}
+	return nil
+}
+
+// linkBinaryAs points the symbolic link called name in binDir at the hvm
+// installed version v of binary b
+func linkBinaryAs(binDir string, hvmHome string, b string, v string, name string) error {
+	srcPath := fmt.Sprintf("%s/%s/%s/%s", hvmHome, b, v, b)
+	destPath := fmt.Sprintf("%s/%s", binDir, name)
 	// Handle the binary symbolic link with jazz-like hands...
 	if fi, err := os.Lstat(destPath); err == nil {
 		if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
@@ -188,18 +658,18 @@ func useBinary(m *UseMeta) error {
 				return fmt.Errorf("failed to unlink %s with error: %+v", destPath, err)
 			}
 		} else {
-			return fmt.Errorf("Path %s exists and is not a symbolic link created by hvm.\nhvm needs your help to resolve this problem; please inspect and move %s, thanks.", destPath, destPath)
+			return fmt.Errorf("Path %s exists and is not a symbolic link created by hvm.\nhvm needs your help to resolve this problem; please inspect and move %s, or run hvm use with --backup to have it renamed out of the way, thanks.", destPath, destPath)
 		}
 	}
 	// XXX: yarrr
 	// else if os.IsNotExist(err) {
 	//     return fmt.Errorf("failed to resolve symbolic link: %+v", err)
 	// }
-	err = os.Symlink(srcPath, destPath)
-	if err != nil {
-		logger.Error("install", "f-use-binary", "symlink", "error", err)
+	if err := os.Symlink(srcPath, destPath); err != nil {
+		if os.IsPermission(err) {
+			return fmt.Errorf("Cannot create symbolic link in %s; permission denied, so run hvm with sufficient privileges (such as with sudo) or choose another global_bin_dir", binDir)
+		}
 		return err
 	}
-	fmt.Println(fmt.Sprintf("Using %s (%s/%s) version %s", b, m.BinaryOS, m.BinaryArch, v))
 	return nil
 }
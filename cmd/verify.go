@@ -0,0 +1,261 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// VerifyMeta contains data for verifying an installed binary version
+type VerifyMeta struct {
+	BinaryArch    string
+	BinaryName    string
+	BinaryOS      string
+	BinaryVersion string
+	Offline       bool
+	LogFile       string
+	UserHome      string
+	HvmHome       string
+}
+
+// verifyCmd checks an installed binary against its published checksum
+var verifyCmd = &cobra.Command{
+	Use:   "verify (<binary>) (--version <version>)",
+	Short: "Verify an installed binary against its published checksum",
+	Long: `
+Verify that an installed binary version matches what is published on
+releases.hashicorp.com.
+
+The published SHA256SUMS file lists checksums of the release archives rather
+than of the binaries inside them, so the release archive is downloaded and
+verified against SHA256SUMS, then the binary it contains is compared to the
+installed binary. Nothing is installed or changed.
+
+A release archive kept with install --keep-archive is used instead of
+downloading it again.
+
+With --offline nothing is downloaded: the installed binary is compared to the
+kept release archive, verified against its kept SHA256SUMS file, or when the
+archive was not kept, to the checksum recorded in its metadata.json at install
+time.
+`,
+	Example: `
+  hvm verify vault --version 1.0.2
+
+  hvm verify vault --version 1.0.2 --offline`,
+	ValidArgs: hvm.SupportedBinaries(),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("requires exactly one argument, the name of a binary to verify.")
+		}
+		if !hvm.IsSupported(args[0]) {
+			return unsupportedError(fmt.Errorf("Cannot verify %q; it is not a supported binary; for a list of supported binaries, use hvm install --help", args[0]))
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		m := VerifyMeta{}
+		userHome, err := userHomeDir()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot access home directory with error: %v", err))
+			os.Exit(1)
+		}
+		m.UserHome = userHome
+		m.HvmHome = HvmDataDir(m.UserHome)
+		m.LogFile = LogFilePath(m.HvmHome)
+		m.BinaryArch = runtime.GOARCH
+		m.BinaryOS = runtime.GOOS
+		m.BinaryName = args[0]
+		m.BinaryVersion = binaryVersion
+		m.Offline = viper.GetBool("offline")
+		b := m.BinaryName
+		v := m.BinaryVersion
+		if _, err := os.Stat(m.HvmHome); os.IsNotExist(err) {
+			err = CreateHvmHome(m.HvmHome)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				os.Exit(1)
+			}
+		}
+		f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot open log file %s with error: %v", m.LogFile, err))
+			os.Exit(1)
+		}
+		defer f.Close()
+		logger := newLogger(f)
+		logger.Info("verify", "run", "start", "binary", b, "version", v)
+
+		installedVersion, err := hvm.IsInstalledVersion(b, v)
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot determine if %s version %s is installed: %v", b, v, err))
+			os.Exit(1)
+		}
+		if !installedVersion {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("%s version %s is not installed.", b, v))
+			os.Exit(1)
+		}
+		var match bool
+		kept := false
+		if spec, ok := hvm.LookupBinary(b); ok {
+			archivePath, _ := hvm.KeptArchive(m.HvmHome, spec, v, m.BinaryOS, m.BinaryArch)
+			kept = archivePath != ""
+		}
+		if m.Offline && !kept {
+			match, err = verifyBinaryOffline(&m)
+		} else {
+			match, err = verifyBinary(cmd.Context(), &m)
+		}
+		if err != nil {
+			logger.Error("verify", "binary", b, "version", v, "error", err.Error())
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot verify %s version %s with error: %v", b, v, err))
+			os.Exit(1)
+		}
+		if !match {
+			logger.Error("verify", "binary", b, "version", v, "issue", "checksum-mismatch")
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("MISMATCH: installed %s version %s does not match the published release.", b, v))
+			os.Exit(1)
+		}
+		logger.Info("verify", "binary", b, "version", v, "match", "true")
+		if m.Offline && !kept {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("OK: installed %s version %s matches the checksum recorded at install time.", b, v))
+			return
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("OK: installed %s version %s matches the published release.", b, v))
+	},
+}
+
+// Initialize the command
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.PersistentFlags().StringVar(&binaryVersion,
+		"version",
+		"",
+		"verify binary version")
+	verifyCmd.MarkFlagRequired("version")
+}
+
+// verifyBinaryOffline returns true if the installed binary matches the checksum
+// recorded in its install metadata
+func verifyBinaryOffline(m *VerifyMeta) (bool, error) {
+	versionPath := fmt.Sprintf("%s/%s/%s", m.HvmHome, m.BinaryName, m.BinaryVersion)
+	md, err := hvm.ReadInstallMetadata(versionPath)
+	if err != nil {
+		return false, err
+	}
+	if md == nil || md.BinarySHA256 == "" {
+		return false, fmt.Errorf("no checksum was recorded when %s version %s was installed", m.BinaryName, m.BinaryVersion)
+	}
+	installedSha, err := hvm.FileSHA256(fmt.Sprintf("%s/%s", versionPath, m.BinaryName))
+	if err != nil {
+		return false, err
+	}
+	return installedSha == md.BinarySHA256, nil
+}
+
+// verifyBinary returns true if the installed binary is identical to the one in
+// the published release archive, which is itself checked against SHA256SUMS; an
+// archive kept at install time is used rather than downloaded, and with
+// m.Offline so is its kept SHA256SUMS file
+func verifyBinary(ctx context.Context, m *VerifyMeta) (bool, error) {
+	b := m.BinaryName
+	v := m.BinaryVersion
+	spec, ok := hvm.LookupBinary(b)
+	if !ok {
+		return false, unsupportedError(fmt.Errorf("%s is not a supported binary", b))
+	}
+	shaFilename, err := spec.ChecksumsName(v)
+	if err != nil {
+		return false, err
+	}
+	keptPath, keptSums := hvm.KeptArchive(m.HvmHome, spec, v, m.BinaryOS, m.BinaryArch)
+	var binarySha []byte
+	if m.Offline && keptSums != "" {
+		binarySha, err = ioutil.ReadFile(keptSums)
+	} else {
+		binarySha, err = hvm.FetchData(ctx, fmt.Sprintf("%s/%s", spec.VersionURL(v), shaFilename))
+	}
+	if err != nil {
+		return false, err
+	}
+	fileSha, err := hvm.ParseSHA256SUMS(binarySha, b, v)
+	if err != nil {
+		return false, err
+	}
+	pkgFilename, err := spec.ArchiveName(v, m.BinaryOS, m.BinaryArch)
+	if err != nil {
+		return false, err
+	}
+	checkSha, err := hvm.ChecksumFor(fileSha, pkgFilename)
+	if err != nil {
+		return false, err
+	}
+	tmpDir, err := ioutil.TempDir("", "hvm-verify")
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(tmpDir)
+	archivePath := keptPath
+	if archivePath == "" {
+		archivePath = fmt.Sprintf("%s/%s", tmpDir, pkgFilename)
+		// The checksum is compared below, once the download is complete
+		fullURL := fmt.Sprintf("%s/%s", spec.VersionURL(v), pkgFilename)
+		dctx, cancel := hvm.DownloadContext(ctx)
+		defer cancel()
+		if err := hvm.ResumableDownload(dctx, archivePath, fullURL, nil); err != nil {
+			return false, err
+		}
+	}
+	archiveSha, err := hvm.FileSHA256(archivePath)
+	if err != nil {
+		return false, err
+	}
+	if archiveSha != checkSha {
+		return false, fmt.Errorf("release archive %s does not match SHA256SUMS", pkgFilename)
+	}
+	releasePath := fmt.Sprintf("%s/%s", tmpDir, b)
+	if err := hvm.ExtractBinary(archivePath, pkgFilename, b, releasePath); err != nil {
+		return false, err
+	}
+	releaseSha, err := hvm.FileSHA256(releasePath)
+	if err != nil {
+		return false, err
+	}
+	installedSha, err := hvm.FileSHA256(fmt.Sprintf("%s/%s/%s/%s", m.HvmHome, b, v, b))
+	if err != nil {
+		return false, err
+	}
+	return installedSha == releaseSha, nil
+}
@@ -31,15 +31,36 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// These are set at build time with, for example:
+// go build -ldflags "-X github.com/brianshumate/hvm/cmd.hvmVersion=0.0.2 -X github.com/brianshumate/hvm/cmd.hvmCommit=$(git rev-parse --short HEAD) -X github.com/brianshumate/hvm/cmd.hvmBuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	// hvmVersion is the version of hvm itself
+	hvmVersion = "0.0.1"
+
+	// hvmCommit is the git commit hvm was built from
+	hvmCommit = "unknown"
+
+	// hvmBuildDate is the date hvm was built on
+	hvmBuildDate = "unknown"
+)
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print hvm version",
 	Long:  `All software has versions. This is the hvm version in use.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("hvm v0.0.1")
+		fmt.Fprintln(cmd.OutOrStdout(), versionString())
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(versionCmd)
+	// Also handle hvm --version
+	rootCmd.Version = hvmVersion
+	rootCmd.SetVersionTemplate(fmt.Sprintf("%s\n", versionString()))
+}
+
+// versionString returns the hvm version along with its build details
+func versionString() string {
+	return fmt.Sprintf("hvm v%s (commit: %s, built: %s)", hvmVersion, hvmCommit, hvmBuildDate)
 }
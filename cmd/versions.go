@@ -0,0 +1,126 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/brianshumate/hvm/pkg/hvm"
+	"github.com/spf13/cobra"
+)
+
+// VersionsMeta contains data for listing available binary versions
+type VersionsMeta struct {
+	BinaryName string
+	Limit      int
+	StableOnly bool
+	LogFile    string
+	UserHome   string
+	HvmHome    string
+}
+
+var (
+	versionsStableOnly bool
+	versionsLimit      int
+)
+
+// versionsCmd lists the versions of a binary published to releases.hashicorp.com
+var versionsCmd = &cobra.Command{
+	Use:   "versions (<binary>) [--stable-only] [--limit <count>]",
+	Short: "List binary versions available from releases.hashicorp.com",
+	Long: `
+List the versions of a binary published to releases.hashicorp.com, sorted
+from newest to oldest.
+`,
+	Example: `
+  hvm versions vault
+
+  hvm versions terraform --stable-only --limit 10`,
+	ValidArgs: hvm.SupportedBinaries(),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("requires exactly one argument, the name of a binary.")
+		}
+		if !hvm.IsSupported(args[0]) {
+			return unsupportedError(fmt.Errorf("Cannot list versions of %q; it is not a supported binary; for a list of supported binaries, use hvm versions --help", args[0]))
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		m := VersionsMeta{}
+		userHome, err := userHomeDir()
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot access home directory with error: %v", err))
+			os.Exit(1)
+		}
+		m.UserHome = userHome
+		m.HvmHome = HvmDataDir(m.UserHome)
+		m.LogFile = LogFilePath(m.HvmHome)
+		m.BinaryName = args[0]
+		m.StableOnly = versionsStableOnly
+		m.Limit = versionsLimit
+		if _, err := os.Stat(m.HvmHome); os.IsNotExist(err) {
+			err = CreateHvmHome(m.HvmHome)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				os.Exit(1)
+			}
+		}
+		f, err := os.OpenFile(m.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot open log file %s with error: %v", m.LogFile, err))
+			os.Exit(1)
+		}
+		defer f.Close()
+		logger := newLogger(f)
+		logger.Info("versions", "run", "start", "binary", m.BinaryName, "stable-only", m.StableOnly, "limit", m.Limit)
+
+		versions, err := hvm.ListRemoteVersions(cmd.Context(), m.BinaryName, m.StableOnly, m.Limit)
+		if err != nil {
+			logger.Error("versions", "binary", m.BinaryName, "error", err.Error())
+			fmt.Fprintln(cmd.OutOrStdout(), fmt.Sprintf("Cannot list %s versions with error: %v", m.BinaryName, err))
+			os.Exit(exitCode(err))
+		}
+		for _, v := range versions {
+			fmt.Fprintln(cmd.OutOrStdout(), v)
+		}
+	},
+}
+
+// Initialize the command
+func init() {
+	rootCmd.AddCommand(versionsCmd)
+	versionsCmd.PersistentFlags().BoolVar(&versionsStableOnly,
+		"stable-only",
+		false,
+		"exclude prerelease versions such as betas and release candidates")
+	versionsCmd.PersistentFlags().IntVar(&versionsLimit,
+		"limit",
+		0,
+		"list only the newest number of versions")
+}
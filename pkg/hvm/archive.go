@@ -0,0 +1,274 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package hvm
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/hashicorp/go-getter"
+)
+
+// archiveFormats are the archive name suffixes hvm can extract, longest first
+// so that .tar.gz is not mistaken for a plain gzip file
+var archiveFormats = []string{"tar.bz2", "tar.gz", "tar.xz", "tbz2", "tgz", "txz", "zip", "dmg", "msi"}
+
+// archiveFormat returns the format of the archive with the given name or path,
+// which is also its go-getter decompressor key, or "" if it is not recognized
+func archiveFormat(name string) string {
+	for _, format := range archiveFormats {
+		if strings.HasSuffix(name, "."+format) {
+			return format
+		}
+	}
+	return ""
+}
+
+// ExtractBinary extracts binary, or binary.exe, from the archive at archivePath
+// to installPath; the archive format is determined from the suffix of name. The
+// other files of an archive holding more than the binary, such as plugins or
+// libraries it needs, are extracted alongside it into the directory of installPath
+func ExtractBinary(archivePath string, name string, binary string, installPath string) error {
+	format := archiveFormat(name)
+	if format == "" {
+		return fmt.Errorf("Cannot extract %s; it is not a zip, tar, dmg or msi archive", name)
+	}
+	tmpDir, err := ioutil.TempDir(filepath.Dir(installPath), ".extract")
+	if err != nil {
+		return fmt.Errorf("Cannot create extraction directory with error: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	switch format {
+	case "dmg":
+		return extractDmgBinary(archivePath, tmpDir, binary, installPath)
+	case "msi":
+		return extractMsiBinary(archivePath, tmpDir, binary, installPath)
+	}
+	d, ok := getter.Decompressors[format]
+	if !ok {
+		return fmt.Errorf("Cannot extract %s archives", format)
+	}
+	if err := d.Decompress(tmpDir, archivePath, true, 0); err != nil {
+		return fmt.Errorf("Cannot extract %s with error: %v", name, err)
+	}
+	src, err := findBinary(tmpDir, binary)
+	if err != nil {
+		return fmt.Errorf("Cannot find %s in %s", binary, name)
+	}
+	files, err := countFiles(tmpDir)
+	if err != nil {
+		return fmt.Errorf("Cannot extract %s with error: %v", name, err)
+	}
+	if files == 1 {
+		return os.Rename(src, installPath)
+	}
+	return installEntries(tmpDir, src, installPath)
+}
+
+// installEntries moves everything extracted to root into the directory of
+// installPath, with the binary at src itself moved to installPath; a binary in
+// a subdirectory of the archive stays where it is, so that it finds the files
+// next to it, and installPath becomes a relative symbolic link to it. Should
+// another entry of the archive have the name of installPath, everything is
+// moved into a dist directory instead, as for an installer
+func installEntries(root string, src string, installPath string) error {
+	versionDir := filepath.Dir(installPath)
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Name() == filepath.Base(installPath) && filepath.Join(root, e.Name()) != src {
+			dist := filepath.Join(versionDir, "dist")
+			if err := os.RemoveAll(dist); err != nil {
+				return err
+			}
+			if err := os.Rename(root, dist); err != nil {
+				return fmt.Errorf("Cannot move archive files to %s with error: %v", dist, err)
+			}
+			return os.Symlink(filepath.Join("dist", rel), installPath)
+		}
+	}
+	for _, e := range entries {
+		path := filepath.Join(root, e.Name())
+		if path == src {
+			if err := os.Rename(src, installPath); err != nil {
+				return err
+			}
+			continue
+		}
+		dst := filepath.Join(versionDir, e.Name())
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+		if err := os.Rename(path, dst); err != nil {
+			return fmt.Errorf("Cannot move %s to %s with error: %v", e.Name(), versionDir, err)
+		}
+	}
+	if filepath.Dir(src) == root {
+		return nil
+	}
+	return os.Symlink(rel, installPath)
+}
+
+// countFiles returns the number of regular files under dir
+func countFiles(dir string) (int, error) {
+	n := 0
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// extractDmgBinary copies binary out of a macOS disk image by attaching it
+// read only at a mount point under tmpDir; when the image holds an installer
+// package instead, as Vagrant's does, the package is expanded instead
+func extractDmgBinary(archivePath string, tmpDir string, binary string, installPath string) error {
+	if runtime.GOOS != "darwin" {
+		return fmt.Errorf("Cannot extract %s; disk images can only be extracted on macOS", filepath.Base(archivePath))
+	}
+	mountPoint := filepath.Join(tmpDir, "mnt")
+	attach := exec.Command("hdiutil", "attach", "-nobrowse", "-readonly", "-mountpoint", mountPoint, archivePath)
+	if out, err := attach.CombinedOutput(); err != nil {
+		return fmt.Errorf("Cannot attach %s with error: %v: %s", archivePath, err, strings.TrimSpace(string(out)))
+	}
+	defer exec.Command("hdiutil", "detach", "-quiet", mountPoint).Run()
+	if src, err := findBinary(mountPoint, binary); err == nil {
+		return copyFile(src, installPath)
+	}
+	pkgs, err := filepath.Glob(filepath.Join(mountPoint, "*.pkg"))
+	if err != nil || len(pkgs) == 0 {
+		return fmt.Errorf("Cannot find %s or an installer package in %s", binary, filepath.Base(archivePath))
+	}
+	expanded := filepath.Join(tmpDir, "pkg")
+	expand := exec.Command("pkgutil", "--expand-full", pkgs[0], expanded)
+	if out, err := expand.CombinedOutput(); err != nil {
+		return fmt.Errorf("Cannot expand %s with error: %v: %s", filepath.Base(pkgs[0]), err, strings.TrimSpace(string(out)))
+	}
+	return installTree(expanded, binary, installPath)
+}
+
+// extractMsiBinary unpacks a Windows installer with an administrative install
+// into tmpDir, which extracts its files without installing anything
+func extractMsiBinary(archivePath string, tmpDir string, binary string, installPath string) error {
+	if runtime.GOOS != "windows" {
+		return fmt.Errorf("Cannot extract %s; Windows installers can only be extracted on Windows", filepath.Base(archivePath))
+	}
+	target := filepath.Join(tmpDir, "msi")
+	unpack := exec.Command("msiexec", "/a", archivePath, "/qn", "TARGETDIR="+target)
+	if out, err := unpack.CombinedOutput(); err != nil {
+		return fmt.Errorf("Cannot unpack %s with error: %v: %s", filepath.Base(archivePath), err, strings.TrimSpace(string(out)))
+	}
+	return installTree(target, binary, installPath)
+}
+
+// installTree installs a binary which needs the rest of the files its
+// installer ships, such as the embedded Ruby of Vagrant; the directory holding
+// bin/<binary> is moved next to installPath as dist and installPath becomes a
+// symbolic link to the binary inside it
+func installTree(root string, binary string, installPath string) error {
+	src, err := findBinary(root, binary)
+	if err != nil {
+		return fmt.Errorf("Cannot find %s in the installer", binary)
+	}
+	binDir := filepath.Dir(src)
+	if filepath.Base(binDir) != "bin" {
+		return fmt.Errorf("Cannot install %s; it is not in a bin directory of the installer", binary)
+	}
+	dist := filepath.Join(filepath.Dir(installPath), "dist")
+	if err := os.RemoveAll(dist); err != nil {
+		return err
+	}
+	if err := os.Rename(filepath.Dir(binDir), dist); err != nil {
+		return fmt.Errorf("Cannot move installer files to %s with error: %v", dist, err)
+	}
+	// The link is absolute as installer scripts such as Vagrant's resolve
+	// their own location by following links one readlink at a time
+	target, err := filepath.Abs(filepath.Join(dist, "bin", filepath.Base(src)))
+	if err != nil {
+		return err
+	}
+	return os.Symlink(target, installPath)
+}
+
+// findBinary returns the path of the first regular file named binary, or
+// binary.exe, found under dir
+func findBinary(dir string, binary string) (string, error) {
+	found := ""
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if found != "" || !info.Mode().IsRegular() {
+			return nil
+		}
+		if info.Name() == binary || info.Name() == binary+".exe" {
+			found = path
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if found == "" {
+		return "", os.ErrNotExist
+	}
+	return found, nil
+}
+
+// copyFile copies the regular file src to dst
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,113 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package hvm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Exit codes hvm exits with, which errors returned by this package carry in an
+// ExitError so that callers can tell why an operation failed
+const (
+	// ExitOK indicates success
+	ExitOK int = 0
+
+	// ExitFailure indicates a general failure
+	ExitFailure int = 1
+
+	// ExitUnsupported indicates an unsupported binary or platform
+	ExitUnsupported int = 2
+
+	// ExitInvalidVersion indicates a version which does not exist or was refused
+	ExitInvalidVersion int = 3
+
+	// ExitNetwork indicates a failure to reach or download from a remote site
+	ExitNetwork int = 4
+
+	// ExitAlreadyInstalled indicates the requested version is already installed
+	ExitAlreadyInstalled int = 5
+
+	// ExitInterrupted indicates the command was interrupted, as by Ctrl-C
+	ExitInterrupted int = 130
+)
+
+// ExitError is an error carrying the exit code hvm should exit with
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+// Error implements error
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// networkError marks err as a failure to reach or download from a remote site
+func networkError(err error) error {
+	return &ExitError{Code: ExitNetwork, Err: err}
+}
+
+// ErrSiteUnavailable indicates a release site answered with something other than
+// what hvm asked for, such as a maintenance or CDN error page
+var ErrSiteUnavailable = errors.New("release site unavailable")
+
+// siteUnavailableError reports that the response from URL, described by reason,
+// is not the expected content; it is a network failure rather than a verdict on
+// the binary or version asked for
+func siteUnavailableError(URL string, reason string) error {
+	return networkError(fmt.Errorf("%w: %s %s; it may be down for maintenance, so try again later", ErrSiteUnavailable, URL, reason))
+}
+
+// ErrOffline indicates that something needed the network while the Offline
+// setting forbids using it
+var ErrOffline = errors.New("hvm is offline, so the network is not used")
+
+// offlineError reports that what, such as "look up the latest vault version",
+// cannot be done without the network
+func offlineError(what string) error {
+	return networkError(fmt.Errorf("Cannot %s: %w", what, ErrOffline))
+}
+
+// ErrNotFound indicates that a release site has nothing at the URL asked for
+var ErrNotFound = errors.New("not found")
+
+// accessDeniedError reports that a release site refused the request to do
+// what, such as "download vault version 1.0.2", answering with status; this is
+// down to the mirror credentials rather than the network
+func accessDeniedError(what string, status string) error {
+	return fmt.Errorf("Cannot %s; access was denied with %s, so check the mirror_token, mirror_password or mirror_headers settings", what, status)
+}
+
+// unsupportedError marks err as caused by an unsupported binary or platform
+func unsupportedError(err error) error {
+	return &ExitError{Code: ExitUnsupported, Err: err}
+}
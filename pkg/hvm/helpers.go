@@ -0,0 +1,1288 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package hvm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/net/html"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-getter"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-version"
+)
+
+const (
+	// CheckpointURLBase is the URL base for CheckPoint API
+	CheckpointURLBase string = "https://checkpoint-api.hashicorp.com"
+
+	// GitHubAPIURLBase is the URL base for the GitHub API
+	GitHubAPIURLBase string = "https://api.github.com"
+
+	// ReleaseURLBase is the URL base for the HashiCorp releases website
+	ReleaseURLBase string = "https://releases.hashicorp.com"
+
+	// Consul binary name
+	Consul string = "consul"
+
+	// ConsulK8s binary name
+	ConsulK8s string = "consul-k8s"
+
+	// ConsulTemplate binary name
+	ConsulTemplate string = "consul-template"
+
+	// EnvConsul binary name
+	EnvConsul string = "envconsul"
+
+	// Nomad binary name
+	Nomad string = "nomad"
+
+	// NomadAutoscaler binary name
+	NomadAutoscaler string = "nomad-autoscaler"
+
+	// Packer binary name
+	Packer string = "packer"
+
+	// Sentinel binary name
+	Sentinel string = "sentinel"
+
+	// Serf binary name
+	Serf string = "serf"
+
+	// Terraform binary name
+	Terraform string = "terraform"
+
+	// TerraformLS binary name
+	TerraformLS string = "terraform-ls"
+
+	// Vagrant binary name
+	Vagrant string = "vagrant"
+
+	// Vault binary name
+	Vault string = "vault"
+)
+
+// SupportedPlatforms maps each operating system to the architectures
+// HashiCorp publishes binaries for on releases.hashicorp.com
+var SupportedPlatforms = map[string][]string{
+	"darwin":  {"amd64", "arm64"},
+	"freebsd": {"386", "amd64", "arm"},
+	"linux":   {"386", "amd64", "arm", "arm64"},
+	"openbsd": {"386", "amd64"},
+	"solaris": {"amd64"},
+	"windows": {"386", "amd64"},
+}
+
+// ArchAliases maps architecture names people commonly use to the names
+// HashiCorp publishes binaries under, which are those of runtime.GOARCH
+var ArchAliases = map[string]string{
+	"aarch64": "arm64",
+	"armv7l":  "arm",
+	"i386":    "386",
+	"i686":    "386",
+	"x64":     "amd64",
+	"x86":     "386",
+	"x86_64":  "amd64",
+}
+
+// InstallMetadata describes how and when a binary version was installed and is
+// stored as metadata.json alongside the binary in its version directory
+type InstallMetadata struct {
+	Binary        string    `json:"binary"`
+	Version       string    `json:"version"`
+	OS            string    `json:"os"`
+	Arch          string    `json:"arch"`
+	InstalledAt   time.Time `json:"installed_at"`
+	SourceURL     string    `json:"source_url"`
+	ArchiveSHA256 string    `json:"archive_sha256"`
+	BinarySHA256  string    `json:"binary_sha256"`
+}
+
+// httpTransport returns the transport shared by every HTTP client hvm uses so
+// that commands making many requests, such as info --check-latest or batch
+// installs, reuse connections; it verifies certificates against the CABundle
+// setting when one is given, unless InsecureSkipVerify is set, and checks release
+// sites against any TLSPins
+func httpTransport(s Settings) (http.RoundTripper, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: s.InsecureSkipVerify,
+		VerifyConnection:   verifyPins(s.TLSPins),
+	}
+	if s.CABundle != "" {
+		pem, err := ioutil.ReadFile(s.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read CA bundle %s with error: %v", s.CABundle, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %s contains no PEM encoded certificates", s.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}, nil
+}
+
+// failedTransport fails every request with the error which kept the shared
+// transport from being constructed, so that it is reported by whatever
+// command first needs the network
+type failedTransport struct {
+	err error
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *failedTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
+
+// verifyPins returns a TLS connection check requiring the verified certificate
+// chain of a release site to include a certificate whose public key matches
+// one of pins, which are base64 encoded SHA-256 hashes of the DER encoded
+// SubjectPublicKeyInfo optionally prefixed with sha256//, the form curl's
+// --pinnedpubkey takes; without pins every connection passes
+func verifyPins(pins []string) func(tls.ConnectionState) error {
+	if len(pins) == 0 {
+		return nil
+	}
+	wanted := map[string]bool{}
+	for _, pin := range pins {
+		wanted[strings.TrimPrefix(strings.TrimSpace(pin), "sha256//")] = true
+	}
+	return func(cs tls.ConnectionState) error {
+		if !isReleaseConnection(cs) {
+			return nil
+		}
+		chains := cs.VerifiedChains
+		// Without verification there are no verified chains, but the pins
+		// still hold the site to the certificates it is expected to present
+		if len(chains) == 0 {
+			chains = [][]*x509.Certificate{cs.PeerCertificates}
+		}
+		for _, chain := range chains {
+			for _, cert := range chain {
+				sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				if wanted[base64.StdEncoding.EncodeToString(sum[:])] {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("no certificate presented by %s matches tls_pins", cs.PeerCertificates[0].Subject)
+	}
+}
+
+// isReleaseConnection returns true if the TLS connection cs is to the host of a
+// releases site in the registry; connections to an IP address carry no server
+// name, so those are recognized by the certificate being valid for the host
+func isReleaseConnection(cs tls.ConnectionState) bool {
+	for _, spec := range loadRegistry() {
+		if spec.GitHub != "" {
+			continue
+		}
+		r, err := url.Parse(spec.ReleaseURL)
+		if err != nil {
+			continue
+		}
+		if cs.ServerName == r.Hostname() {
+			return true
+		}
+		if cs.ServerName == "" && len(cs.PeerCertificates) > 0 && cs.PeerCertificates[0].VerifyHostname(r.Hostname()) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	httpClientsMu sync.Mutex
+	metadataHTTP  *http.Client
+	downloadHTTP  *http.Client
+)
+
+// httpClients returns the shared clients, constructing them from the settings
+// the first time they are needed
+func httpClients() (*http.Client, *http.Client) {
+	httpClientsMu.Lock()
+	defer httpClientsMu.Unlock()
+	if metadataHTTP == nil {
+		s := currentSettings()
+		transport, err := httpTransport(s)
+		if err != nil {
+			transport = &failedTransport{err: err}
+		}
+		// Whatever is not refused sooner with a clearer message ends here
+		if s.Offline {
+			transport = &failedTransport{err: offlineError("connect to a remote site")}
+		}
+		metadataHTTP = &http.Client{Transport: transport, Timeout: s.MetadataTimeout}
+		// Downloads are bounded by DownloadContext instead of a client timeout
+		downloadHTTP = &http.Client{Transport: transport}
+	}
+	return metadataHTTP, downloadHTTP
+}
+
+// resetHTTPClients discards the shared clients so that they are constructed
+// again from new settings
+func resetHTTPClients() {
+	httpClientsMu.Lock()
+	defer httpClientsMu.Unlock()
+	metadataHTTP = nil
+	downloadHTTP = nil
+}
+
+// MetadataClient returns the HTTP client for small requests such as version
+// lookups and SHA256SUMS files which gives up after the MetadataTimeout setting
+func MetadataClient() *http.Client {
+	c, _ := httpClients()
+	return c
+}
+
+// downloadClient returns the HTTP client for archive downloads
+func downloadClient() *http.Client {
+	_, c := httpClients()
+	return c
+}
+
+// mirrorHeaders returns the HTTP headers configured with the MirrorToken or
+// MirrorUsername and MirrorPassword settings, along with any MirrorHeaders,
+// for authenticating to a private release mirror
+func mirrorHeaders() http.Header {
+	s := currentSettings()
+	h := http.Header{}
+	if s.MirrorToken != "" {
+		h.Set("Authorization", "Bearer "+s.MirrorToken)
+	} else if s.MirrorUsername != "" {
+		credentials := s.MirrorUsername + ":" + s.MirrorPassword
+		h.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+	for k, v := range s.MirrorHeaders {
+		h.Set(k, v)
+	}
+	return h
+}
+
+// mirrorHeadersFor returns the configured mirror headers if rawURL is on the
+// host of a releases site in the registry, so that mirror credentials are
+// never sent elsewhere, such as to GitHub
+func mirrorHeadersFor(rawURL string) http.Header {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return http.Header{}
+	}
+	for _, spec := range loadRegistry() {
+		if spec.GitHub != "" {
+			continue
+		}
+		if r, err := url.Parse(spec.ReleaseURL); err == nil && r.Host == u.Host {
+			return mirrorHeaders()
+		}
+	}
+	return http.Header{}
+}
+
+// setMirrorHeaders adds the configured mirror headers for its URL to req
+func setMirrorHeaders(req *http.Request) {
+	for k, v := range mirrorHeadersFor(req.URL.String()) {
+		req.Header[k] = v
+	}
+}
+
+// WithHTTPGetter is a go-getter client option making http and https requests
+// through the shared download client, sending header with each request
+func WithHTTPGetter(header http.Header) getter.ClientOption {
+	return func(c *getter.Client) error {
+		getters := make(map[string]getter.Getter, len(getter.Getters))
+		for k, g := range getter.Getters {
+			getters[k] = g
+		}
+		httpGetter := &getter.HttpGetter{Netrc: true, Header: header, Client: downloadClient()}
+		getters["http"] = httpGetter
+		getters["https"] = httpGetter
+		c.Getters = getters
+		return nil
+	}
+}
+
+// metadataAttempts is the number of times a rate limited metadata request is tried
+const metadataAttempts = 4
+
+// maxRetryWait bounds how long a single Retry-After is honored
+const maxRetryWait = 30 * time.Second
+
+// DoMetadataRequest makes the metadata request req, retrying with backoff when
+// the server is rate limiting (429) or unavailable (503) and honoring any
+// Retry-After header, so that tight loops of hvm calls do not fail outright
+func DoMetadataRequest(req *http.Request) (*http.Response, error) {
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		resp, err := MetadataClient().Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if attempt == metadataAttempts || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+			return resp, nil
+		}
+		wait := retryAfter(resp.Header.Get("Retry-After"), backoff)
+		resp.Body.Close()
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// retryAfter returns the wait a Retry-After header value asks for, given in
+// seconds or as an HTTP date, bounded by maxRetryWait, or fallback without one
+func retryAfter(value string, fallback time.Duration) time.Duration {
+	wait := fallback
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		wait = time.Duration(seconds) * time.Second
+	} else if t, err := http.ParseTime(value); err == nil {
+		wait = time.Until(t)
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxRetryWait {
+		wait = maxRetryWait
+	}
+	return wait
+}
+
+// DownloadContext returns ctx bounded by the DownloadTimeout setting, which
+// is kept separate from MetadataTimeout as downloads can legitimately take
+// minutes; a DownloadTimeout of 0 leaves downloads unbounded
+func DownloadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d := currentSettings().DownloadTimeout; d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return context.WithCancel(ctx)
+}
+
+// downloadAttempts is the number of times ResumableDownload tries before giving up
+const downloadAttempts = 3
+
+// ResumableDownload downloads the http or https URL src to dst through a dst.part
+// file which is kept between attempts, and between runs of hvm, so that an
+// interrupted download continues with a Range request instead of starting over
+func ResumableDownload(ctx context.Context, dst string, src string, tracker getter.ProgressTracker) error {
+	if currentSettings().Offline {
+		return offlineError(fmt.Sprintf("download %s", src))
+	}
+	partPath := dst + ".part"
+	var err error
+	for attempt := 1; attempt <= downloadAttempts; attempt++ {
+		err = downloadPart(ctx, partPath, src, tracker)
+		if err == nil {
+			return os.Rename(partPath, dst)
+		}
+		var e *ExitError
+		if ctx.Err() != nil || (errors.As(err, &e) && e.Code != ExitNetwork) {
+			break
+		}
+	}
+	return err
+}
+
+// downloadPart appends whatever is missing from partPath from src; an attempt
+// which receives nothing for the DownloadStallTimeout setting is abandoned
+// so that ResumableDownload can try again rather than wait on a dead connection
+func downloadPart(ctx context.Context, partPath string, src string, tracker getter.ProgressTracker) (err error) {
+	var offset int64
+	if fi, err := os.Stat(partPath); err == nil {
+		offset = fi.Size()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stalled atomic.Bool
+	stallTimeout := currentSettings().DownloadStallTimeout
+	var watchdog *time.Timer
+	if stallTimeout > 0 {
+		watchdog = time.AfterFunc(stallTimeout, func() {
+			stalled.Store(true)
+			cancel()
+		})
+		defer watchdog.Stop()
+		defer func() {
+			if err != nil && stalled.Load() {
+				err = networkError(fmt.Errorf("download of %s stalled; nothing was received for %s", src, stallTimeout))
+			}
+		}()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "hvm-oss-http-client")
+	setMirrorHeaders(req)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := downloadClient().Do(req)
+	if err != nil {
+		return networkError(err)
+	}
+	defer resp.Body.Close()
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// The server ignored the Range request so start from the beginning
+		offset = 0
+		flags |= os.O_TRUNC
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The part file is already complete, or is junk; the checksum decides
+		return nil
+	default:
+		// Worded like go-getter so a missing release is reported the same way
+		err := fmt.Errorf("bad response code: %d", resp.StatusCode)
+		if resp.StatusCode >= 500 {
+			return networkError(err)
+		}
+		return err
+	}
+	f, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var body io.ReadCloser = resp.Body
+	if watchdog != nil {
+		body = &stallReader{ReadCloser: body, watchdog: watchdog, timeout: stallTimeout}
+	}
+	if tracker != nil {
+		total := resp.ContentLength
+		if total > 0 {
+			total += offset
+		}
+		body = tracker.TrackProgress(src, offset, total, body)
+		defer body.Close()
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		return networkError(err)
+	}
+	return nil
+}
+
+// stallReader restarts the watchdog timer of a download whenever data arrives
+type stallReader struct {
+	io.ReadCloser
+	watchdog *time.Timer
+	timeout  time.Duration
+}
+
+// Read implements io.Reader
+func (r *stallReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	if n > 0 {
+		r.watchdog.Reset(r.timeout)
+	}
+	return n, err
+}
+
+// FetchData returns the body of the document at URL
+func FetchData(ctx context.Context, URL string) ([]byte, error) {
+	logger := logger()
+	if currentSettings().Offline {
+		return nil, offlineError(fmt.Sprintf("fetch %s", URL))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, URL, nil)
+	if err != nil {
+		logger.Error("helper", "Cannot create request with error", err.Error())
+		return nil, fmt.Errorf("cannot create request with error: %v", err)
+	}
+	setMirrorHeaders(req)
+	response, err := DoMetadataRequest(req)
+	if err != nil {
+		logger.Error("helper", "Cannot fetch data with error", err.Error())
+		return nil, networkError(fmt.Errorf("cannot fetch data with error: %v", err))
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		logger.Error("helper", "Cannot fetch data with error", response.Status, "url", URL)
+		// Only a server error may go away by trying again later
+		switch {
+		case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+			return nil, accessDeniedError(fmt.Sprintf("fetch %s", URL), response.Status)
+		case response.StatusCode == http.StatusNotFound:
+			return nil, fmt.Errorf("cannot fetch %s: %w", URL, ErrNotFound)
+		case response.StatusCode >= 500:
+			return nil, networkError(fmt.Errorf("cannot fetch data with error: %s", response.Status))
+		}
+		return nil, fmt.Errorf("cannot fetch %s with error: %s", URL, response.Status)
+	}
+	// The files fetched here are never web pages, so one is an error page
+	if strings.HasPrefix(response.Header.Get("Content-Type"), "text/html") {
+		err = siteUnavailableError(URL, "returned a web page instead of the file")
+		logger.Error("helper", "Cannot fetch data with error", err.Error())
+		return nil, err
+	}
+	var fetchData bytes.Buffer
+	_, err = io.Copy(&fetchData, response.Body)
+	if err != nil {
+		logger.Error("helper", "cannot fetch data with error", err.Error())
+		return nil, fmt.Errorf("Cannot fetch data with bytes buffer with error: %v", err)
+	}
+	return fetchData.Bytes(), nil
+}
+
+// GetLatestVersion returns the latest available binary version from releases.hashicorp.com
+func GetLatestVersion(ctx context.Context, binary string) (string, error) {
+	logger := logger()
+	logger.Debug("helper", "f-get-latest-version", binary)
+	if currentSettings().Offline {
+		return "", offlineError(fmt.Sprintf("look up the latest %s version", binary))
+	}
+	source := currentSettings().VersionSource
+	if source != "releases" && source != "checkpoint" {
+		return "", fmt.Errorf("unknown version_source %q; expected releases or checkpoint", source)
+	}
+	spec, ok := LookupBinary(binary)
+	if !ok {
+		logger.Warn("helper", "binary", binary, "unsupported-binary", "Binary not in the registry or otherwise not supported.")
+		return "", unsupportedError(fmt.Errorf("Binary currently unsupported"))
+	}
+	// The releases page is authoritative as Checkpoint sometimes lags
+	// behind it, so Checkpoint is only used when explicitly configured,
+	// and never at all when DisableCheckpoint is set
+	if spec.Checkpoint && source == "checkpoint" {
+		if !currentSettings().DisableCheckpoint {
+			return latestFromCheckpoint(ctx, binary, logger)
+		}
+		logger.Info("helper", "f-get-latest-version", binary, "checkpoint", "disabled")
+	}
+	// Some binary latest versions cannot be queried through the Checkpoint API.
+	// Those binaries must unfortunately be queried using an HTML scraping approach instead.
+	logger.Debug("helper", "f-get-latest-version-html-scrape-url-base", spec.ReleaseURL)
+	logger.Debug("helper", "f-get-latest-version-html-scrape-binary-name", binary)
+	latestVersion, err := latestFromReleases(ctx, binary)
+	if err != nil {
+		logger.Error("helper", "f-get-latest-version", "html-scrape-error", err.Error())
+		return "", fmt.Errorf("Cannot get %s release versions with error: %w", binary, err)
+	}
+	return latestVersion, nil
+}
+
+// latestFromCheckpoint returns the latest version of binary reported by the Checkpoint API
+func latestFromCheckpoint(ctx context.Context, binary string, logger hclog.Logger) (string, error) {
+	m := struct {
+		BinaryLatestVersion string `json:"current_version"`
+	}{}
+	// Guarantee that Checkpoint is not contacted even by a future caller
+	if currentSettings().DisableCheckpoint {
+		return "", errors.New("the Checkpoint API is disabled by disable_checkpoint")
+	}
+	logger.Debug("helper", "f-get-latest-version-checkpoint-url-base", CheckpointURLBase)
+	logger.Debug("helper", "f-get-latest-version-checkpoint-binary-name", binary)
+	checkpointDataURL := fmt.Sprintf("%s/v1/check/%s", CheckpointURLBase, binary)
+	logger.Debug("helper", "f-get-latest-version-checkpoint-data-url", checkpointDataURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, checkpointDataURL, nil)
+	if err != nil {
+		logger.Error("helper", "f-get-latest-version", "request-error", err.Error())
+		return "", err
+	}
+	req.Header.Set("User-Agent", "hvm-oss-http-client")
+	res, err := DoMetadataRequest(req)
+	if err != nil {
+		logger.Error("helper", "f-get-latest-version", "get-error", err.Error())
+		return "", networkError(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logger.Error("helper", "f-get-latest-version", "status", res.Status)
+		return "", networkError(fmt.Errorf("unexpected response from Checkpoint: %s", res.Status))
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		logger.Error("helper", "f-get-latest-version", "read-body-error", err.Error())
+		return "", err
+	}
+	err = json.Unmarshal(body, &m)
+	if err != nil {
+		logger.Error("helper", "f-get-latest-version", "json-unmarshall-error", err.Error())
+		return "", fmt.Errorf("cannot unmarshal JSON with error: %v", err)
+	}
+	// Ensure that we get something like a valid version back from the API
+	// and not a maintenance page or similar...
+	checkpointLatestVersion, err := version.NewVersion(m.BinaryLatestVersion)
+	if err != nil {
+		logger.Error("helper", "issue", "cannot determine comparison version", "error", err.Error())
+		return "", err
+	}
+	constraints, err := version.NewConstraint(">= 0.0.1")
+	if err != nil {
+		logger.Error("helper", "f-get-latest-version", "issue", "cannot determine comparison constraints", "error", err.Error())
+		return "", err
+	}
+	if constraints.Check(checkpointLatestVersion) {
+		logger.Debug("helper", "f-get-latest-version", "chcked-version", "version", checkpointLatestVersion, "constraints", constraints)
+	} else {
+		// Eh oh, something is wrong!
+		logger.Error("helper", "f-get-latest-version", "issue", "unexpected-checkpoint-api-value", m.BinaryLatestVersion)
+		return "", fmt.Errorf("problem determining latest binary version")
+	}
+	return m.BinaryLatestVersion, nil
+}
+
+// IsInstalledVersion determines if specified binary version is already installed by hvm
+func IsInstalledVersion(binary string, checkVersion string) (bool, error) {
+	hvmHome, err := dataDir()
+	if err != nil {
+		return false, err
+	}
+	logger().Debug("helper", "is-installed-version", binary, "check version", checkVersion)
+	// Check for the binary itself so that a version directory left behind by an
+	// interrupted download is not mistaken for an installed version
+	fullPath := fmt.Sprintf("%s/%s/%s/%s", hvmHome, binary, checkVersion, binary)
+	// :phew:
+	if _, err := os.Stat(fullPath); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+// NormalizeArch returns the published name of architecture binaryArch, so
+// that aliases such as x86_64 or aarch64 resolve to amd64 or arm64; names
+// without an alias are returned unchanged
+func NormalizeArch(binaryArch string) string {
+	if a, ok := ArchAliases[strings.ToLower(binaryArch)]; ok {
+		return a
+	}
+	return binaryArch
+}
+
+// ValidatePlatform returns an error if binaries are not published for the
+// specified operating system and architecture combination
+func ValidatePlatform(binaryOS string, binaryArch string) error {
+	archs, ok := SupportedPlatforms[binaryOS]
+	if !ok {
+		return fmt.Errorf("unsupported operating system %q", binaryOS)
+	}
+	for _, a := range archs {
+		if a == binaryArch {
+			return nil
+		}
+	}
+	return fmt.Errorf("binaries are not published for %s/%s", binaryOS, binaryArch)
+}
+
+// ValidateBinaryPlatform returns an error if binary is not published for the
+// specified operating system and architecture combination
+func ValidateBinaryPlatform(binary string, binaryOS string, binaryArch string) error {
+	spec, ok := LookupBinary(binary)
+	if !ok || len(spec.Platforms) == 0 {
+		return ValidatePlatform(binaryOS, binaryArch)
+	}
+	for _, a := range spec.Platforms[binaryOS] {
+		if a == binaryArch {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not published for %s/%s", binary, binaryOS, binaryArch)
+}
+
+// PlatformVersion returns the name of the directory a binary version is installed into;
+// versions for the host platform use the bare version while versions for other
+// platforms are suffixed with the operating system and architecture
+func PlatformVersion(binaryVersion string, binaryOS string, binaryArch string) string {
+	if binaryOS == runtime.GOOS && binaryArch == runtime.GOARCH {
+		return binaryVersion
+	}
+	return fmt.Sprintf("%s_%s_%s", binaryVersion, binaryOS, binaryArch)
+}
+
+// withQuery returns the URL or go-getter source u with the query parameter key=value added
+func withQuery(u string, key string, value string) string {
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%s%s=%s", u, sep, key, value)
+}
+
+// FileSHA256 returns the hex encoded SHA-256 sum of the file at path
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("Cannot open %s with error: %v", path, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("Cannot hash %s with error: %v", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ParseSHA256SUMS parses the contents of a <binary>_<version>_SHA256SUMS file
+// into a map of package filename to SHA-256 sum; blank lines are skipped while
+// malformed lines result in an error
+func ParseSHA256SUMS(data []byte, binary string, binaryVersion string) (map[string]string, error) {
+	// Nomad 0.7.0-beta1 and newer list filenames with a leading "./"
+	trimDotSlash := false
+	if binary == Nomad {
+		v, err := version.NewVersion(binaryVersion)
+		if err != nil {
+			return nil, fmt.Errorf("cannot determine Nomad comparison version with error: %v", err)
+		}
+		constraints, err := version.NewConstraint(">= 0.7.0-beta1")
+		if err != nil {
+			return nil, fmt.Errorf("cannot determine Nomad version constraints with error: %v", err)
+		}
+		trimDotSlash = constraints.Check(v)
+	}
+	fileSha := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		s := strings.Fields(scanner.Text())
+		if len(s) == 0 {
+			continue
+		}
+		if len(s) != 2 || len(s[0]) != sha256.Size*2 {
+			return nil, fmt.Errorf("malformed SHA256SUMS line %d: %q", line, scanner.Text())
+		}
+		if _, err := hex.DecodeString(s[0]); err != nil {
+			return nil, fmt.Errorf("malformed SHA256SUMS line %d: %q", line, scanner.Text())
+		}
+		filename := s[1]
+		if trimDotSlash {
+			filename = strings.TrimPrefix(filename, "./")
+		}
+		fileSha[filename] = s[0]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return fileSha, nil
+}
+
+// ChecksumFor returns the SHA-256 sum of pkgFilename from the parsed SHA256SUMS
+// fileSha, or an error when the file lists no archive of that binary and version
+func ChecksumFor(fileSha map[string]string, pkgFilename string) (string, error) {
+	checkSha, ok := fileSha[pkgFilename]
+	if !ok {
+		return "", fmt.Errorf("no checksum for %s in SHA256SUMS", pkgFilename)
+	}
+	return checkSha, nil
+}
+
+// WriteInstallMetadata writes md as metadata.json into the version directory versionPath
+func WriteInstallMetadata(versionPath string, md *InstallMetadata) error {
+	data, err := json.MarshalIndent(md, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Cannot marshal install metadata with error: %v", err)
+	}
+	metadataFile := fmt.Sprintf("%s/metadata.json", versionPath)
+	if err := ioutil.WriteFile(metadataFile, data, 0644); err != nil {
+		return fmt.Errorf("Cannot write %s with error: %v", metadataFile, err)
+	}
+	return nil
+}
+
+// ReadInstallMetadata reads metadata.json from the version directory versionPath;
+// versions installed before metadata was recorded return nil without error
+func ReadInstallMetadata(versionPath string) (*InstallMetadata, error) {
+	metadataFile := fmt.Sprintf("%s/metadata.json", versionPath)
+	data, err := ioutil.ReadFile(metadataFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Cannot read %s with error: %v", metadataFile, err)
+	}
+	md := InstallMetadata{}
+	if err := json.Unmarshal(data, &md); err != nil {
+		return nil, fmt.Errorf("Cannot unmarshal %s with error: %v", metadataFile, err)
+	}
+	return &md, nil
+}
+
+// AcquireLock takes an advisory lock named name under the locks directory of
+// hvmHome by exclusively creating a lock file; the returned function releases it
+func AcquireLock(hvmHome string, name string) (func(), error) {
+	lockPath := fmt.Sprintf("%s/locks", hvmHome)
+	if err := os.MkdirAll(lockPath, 0755); err != nil {
+		return nil, fmt.Errorf("Cannot create directory %s with error: %v", lockPath, err)
+	}
+	lockFile := fmt.Sprintf("%s/%s.lock", lockPath, name)
+	f, err := os.OpenFile(lockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			holder, _ := ioutil.ReadFile(lockFile)
+			return nil, fmt.Errorf("%s is locked by another hvm process (pid %s); if no other hvm is running, remove %s and try again", name, strings.TrimSpace(string(holder)), lockFile)
+		}
+		return nil, fmt.Errorf("Cannot create lock file %s with error: %v", lockFile, err)
+	}
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+	f.Close()
+	return func() {
+		os.Remove(lockFile)
+	}, nil
+}
+
+// ListLocalVersions gets a list of locally installed versions for the host
+// platform sorted from oldest to newest
+func ListLocalVersions(binary string) ([]string, error) {
+	hvmHome, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	binaryPath := fmt.Sprintf("%s/%s", hvmHome, binary)
+	entries, err := ioutil.ReadDir(binaryPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("Cannot read directory %s with error: %v", binaryPath, err)
+	}
+	versions := version.Collection{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		// Versions installed for other platforms are suffixed and skipped here
+		v, err := version.NewVersion(e.Name())
+		if err != nil || v.Original() != e.Name() {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Sort(versions)
+	localVersions := []string{}
+	for _, v := range versions {
+		localVersions = append(localVersions, v.Original())
+	}
+	return localVersions, nil
+}
+
+// CheckVersionFormat returns an error describing what is wrong with binaryVersion
+// if it is not of the major.minor.patch form used by releases, optionally with
+// a prerelease or metadata suffix; this is checked before any network request
+func CheckVersionFormat(binaryVersion string) error {
+	v, err := version.NewVersion(binaryVersion)
+	if err != nil {
+		if _, cerr := version.NewConstraint(binaryVersion); cerr == nil {
+			return fmt.Errorf("%q is a version constraint; give an exact version such as 1.2.3 instead", binaryVersion)
+		}
+		return fmt.Errorf("%q is not a version; give a version such as 1.2.3 or 1.2.3-beta1", binaryVersion)
+	}
+	if len(v.Segments()) > 3 {
+		return fmt.Errorf("%q has too many version segments; give a version such as 1.2.3", binaryVersion)
+	}
+	if strings.HasPrefix(binaryVersion, "v") {
+		return fmt.Errorf("%q begins with v, which release versions do not; give %s instead", binaryVersion, strings.TrimPrefix(binaryVersion, "v"))
+	}
+	return nil
+}
+
+// ValidateVersion accepts a binary name and version number then validates it against all versions
+// from releases.hashicorp.com returning true if the proposed version number matches a version
+// listed there or false if not found or an error occurs, along with the versions it checked
+// against so that callers need not fetch them again
+func ValidateVersion(ctx context.Context, binary string, binaryVersion string) (bool, []string, error) {
+	validVersion := false
+	logger := logger()
+	logger.Info("helper", "validateversion", binary, "check version", binaryVersion)
+	binaryVersions, err := ReleaseVersions(ctx, binary)
+	if err != nil {
+		logger.Error("helper", "failed to get release versions with error", err.Error())
+		return validVersion, nil, err
+	}
+	// we have relatively small slices, so...
+	logger.Info("helper", "Versions", binaryVersions)
+	for _, n := range binaryVersions {
+		if binaryVersion == n {
+			validVersion = true
+			return validVersion, binaryVersions, nil
+		}
+	}
+	return validVersion, binaryVersions, nil
+}
+
+// ListRemoteVersions returns the versions of binary published on releases.hashicorp.com
+// sorted newest first, optionally without prereleases and limited to the newest limit
+// versions when limit is greater than 0; entries which are not versions are dropped
+func ListRemoteVersions(ctx context.Context, binary string, stableOnly bool, limit int) ([]string, error) {
+	releaseVersions, err := ReleaseVersions(ctx, binary)
+	if err != nil {
+		return nil, err
+	}
+	return SortVersions(releaseVersions, stableOnly, limit), nil
+}
+
+// SortVersions sorts a list of versions such as the one returned by ValidateVersion
+// newest first in the same way as ListRemoteVersions, without fetching it again
+func SortVersions(releaseVersions []string, stableOnly bool, limit int) []string {
+	versions := version.Collection{}
+	for _, rv := range releaseVersions {
+		v, err := version.NewVersion(rv)
+		if err != nil {
+			continue
+		}
+		if stableOnly && v.Prerelease() != "" {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Sort(sort.Reverse(versions))
+	remoteVersions := []string{}
+	for _, v := range versions {
+		if limit > 0 && len(remoteVersions) == limit {
+			break
+		}
+		remoteVersions = append(remoteVersions, v.Original())
+	}
+	return remoteVersions
+}
+
+// maxSuggestDistance is the largest edit distance at which a published version is still
+// suggested in place of one that does not exist
+const maxSuggestDistance = 2
+
+// SuggestVersions returns up to three of versions which are closest to the mistyped version
+// binaryVersion by edit distance, newest first, or none when nothing is close enough
+func SuggestVersions(binaryVersion string, versions []string) []string {
+	best := maxSuggestDistance + 1
+	suggestions := []string{}
+	for _, v := range SortVersions(versions, false, 0) {
+		d := editDistance(binaryVersion, v)
+		if d > maxSuggestDistance {
+			continue
+		}
+		if d < best {
+			best = d
+			suggestions = []string{}
+		}
+		if d == best && len(suggestions) < 3 {
+			suggestions = append(suggestions, v)
+		}
+	}
+	return suggestions
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// latestFromReleases returns the highest stable version of binary listed on
+// releases.hashicorp.com regardless of the order of the page; prereleases and
+// builds with metadata such as Vault Enterprise "+ent" versions are ignored
+func latestFromReleases(ctx context.Context, binary string) (string, error) {
+	releaseVersions, err := ReleaseVersions(ctx, binary)
+	if err != nil {
+		return "", err
+	}
+	var latest *version.Version
+	for _, rv := range releaseVersions {
+		v, err := version.NewVersion(rv)
+		if err != nil || v.Prerelease() != "" || v.Metadata() != "" {
+			continue
+		}
+		if latest == nil || v.GreaterThan(latest) {
+			latest = v
+		}
+	}
+	if latest == nil {
+		return "", fmt.Errorf("no %s versions found on %s", binary, releaseURL(binary))
+	}
+	return latest.Original(), nil
+}
+
+var (
+	// releaseVersionsCache holds the versions scraped from the releases
+	// index page per binary for the duration of a single hvm run
+	releaseVersionsCache = map[string][]string{}
+	// releaseVersionsLocks serialize the lookups of each binary so that it is
+	// fetched once, without one binary's fetch holding up another's
+	releaseVersionsLocks = map[string]*sync.Mutex{}
+	// releaseVersionsMu guards the two maps above, and is never held across a fetch
+	releaseVersionsMu sync.Mutex
+)
+
+// releaseVersionsLock returns the lock serializing the version lookups of binary
+func releaseVersionsLock(binary string) *sync.Mutex {
+	releaseVersionsMu.Lock()
+	defer releaseVersionsMu.Unlock()
+	lock, ok := releaseVersionsLocks[binary]
+	if !ok {
+		lock = &sync.Mutex{}
+		releaseVersionsLocks[binary] = lock
+	}
+	return lock
+}
+
+// cachedReleaseVersions returns the versions of binary already listed by this run
+func cachedReleaseVersions(binary string) ([]string, bool) {
+	releaseVersionsMu.Lock()
+	defer releaseVersionsMu.Unlock()
+	versions, ok := releaseVersionsCache[binary]
+	return versions, ok
+}
+
+// cacheReleaseVersions keeps the versions of binary for the rest of this run
+func cacheReleaseVersions(binary string, versions []string) {
+	releaseVersionsMu.Lock()
+	defer releaseVersionsMu.Unlock()
+	releaseVersionsCache[binary] = versions
+}
+
+// ReleaseVersions returns all versions of a binary listed on releases.hashicorp.com in page
+// order; results are cached in memory for the life of the process and on disk under
+// the cache directory for the CacheTTL setting (a CacheTTL of 0 disables the disk cache);
+// with the Offline setting even the caches are not used, so that the outcome does
+// not depend on how long ago the versions were last listed
+func ReleaseVersions(ctx context.Context, binary string) ([]string, error) {
+	if currentSettings().Offline {
+		return nil, offlineError(fmt.Sprintf("list the released %s versions", binary))
+	}
+	lock := releaseVersionsLock(binary)
+	lock.Lock()
+	defer lock.Unlock()
+	if versions, ok := cachedReleaseVersions(binary); ok {
+		return versions, nil
+	}
+	hvmHome, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	cacheFile := fmt.Sprintf("%s/cache/%s_versions.json", hvmHome, binary)
+	ttl := currentSettings().CacheTTL
+	if versions, ok := readVersionsCache(cacheFile, ttl); ok {
+		cacheReleaseVersions(binary, versions)
+		return versions, nil
+	}
+	if spec, ok := LookupBinary(binary); ok && spec.GitHub != "" {
+		versions, err := githubReleaseVersions(ctx, spec)
+		if err != nil {
+			return nil, err
+		}
+		cacheReleaseVersions(binary, versions)
+		if ttl > 0 {
+			writeVersionsCache(cacheFile, versions)
+		}
+		return versions, nil
+	}
+	binaryVersions := []string{}
+	indexURL := fmt.Sprintf("%s/%s", releaseURL(binary), binary)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, indexURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request with error: %v", err)
+	}
+	setMirrorHeaders(req)
+	resp, err := DoMetadataRequest(req)
+	if err != nil {
+		return nil, networkError(fmt.Errorf("failed to get url with error: %v", err))
+	}
+	defer resp.Body.Close()
+	// A release site which does not publish the binary at all answered, so
+	// this is no network failure and retrying would not help
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, unsupportedError(fmt.Errorf("no %s releases are published at %s (%s)", binary, indexURL, resp.Status))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, siteUnavailableError(indexURL, fmt.Sprintf("returned %s", resp.Status))
+	}
+	z := html.NewTokenizer(bufio.NewReader(resp.Body))
+	for done := false; !done; {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() != io.EOF {
+				return nil, fmt.Errorf("failed to parse releases page with error: %v", z.Err())
+			}
+			done = true
+		case html.StartTagToken:
+			t := z.Token()
+			if t.Data != "a" {
+				continue
+			}
+			z.Next()
+			t = z.Token()
+			// Only <binary>_<version> anchors are versions; this skips "../"
+			// along with any header or navigation links on the page
+			prefix := fmt.Sprintf("%s_", binary)
+			if !strings.HasPrefix(t.Data, prefix) {
+				continue
+			}
+			binaryVersions = append(binaryVersions, strings.TrimPrefix(t.Data, prefix))
+		}
+	}
+	// Every binary hvm supports has releases, so a page without any is a
+	// maintenance or error page which must not be taken, or cached, as the
+	// real list; otherwise every version would be reported as invalid
+	if len(binaryVersions) == 0 {
+		return nil, siteUnavailableError(indexURL, fmt.Sprintf("returned a page without any %s versions", binary))
+	}
+	cacheReleaseVersions(binary, binaryVersions)
+	if ttl > 0 {
+		writeVersionsCache(cacheFile, binaryVersions)
+	}
+	return binaryVersions, nil
+}
+
+// githubReleasePages bounds the pages of releases read from the GitHub API
+const githubReleasePages = 10
+
+// githubReleaseVersions returns the versions of the releases of a binary
+// published on GitHub, newest first as the API lists them; drafts and tags
+// not matching the tag template are skipped. Set GITHUB_TOKEN to raise the
+// API rate limit.
+func githubReleaseVersions(ctx context.Context, spec *BinarySpec) ([]string, error) {
+	versions := []string{}
+	for page := 1; page <= githubReleasePages; page++ {
+		releasesURL := fmt.Sprintf("%s/repos/%s/releases?per_page=100&page=%d", GitHubAPIURLBase, spec.GitHub, page)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, releasesURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request with error: %v", err)
+		}
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("User-Agent", "hvm-oss-http-client")
+		if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := DoMetadataRequest(req)
+		if err != nil {
+			return nil, networkError(fmt.Errorf("failed to get url with error: %v", err))
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, networkError(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, networkError(fmt.Errorf("unexpected response from GitHub for %s: %s", spec.GitHub, resp.Status))
+		}
+		releases := []struct {
+			TagName string `json:"tag_name"`
+			Draft   bool   `json:"draft"`
+		}{}
+		if err := json.Unmarshal(body, &releases); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal JSON with error: %v", err)
+		}
+		for _, r := range releases {
+			if r.Draft {
+				continue
+			}
+			if v, ok := spec.TagVersion(r.TagName); ok {
+				versions = append(versions, v)
+			}
+		}
+		if len(releases) < 100 {
+			break
+		}
+	}
+	return versions, nil
+}
+
+// readVersionsCache returns the versions stored in cacheFile if it is younger than ttl
+func readVersionsCache(cacheFile string, ttl time.Duration) ([]string, bool) {
+	if ttl <= 0 {
+		return nil, false
+	}
+	fi, err := os.Stat(cacheFile)
+	if err != nil || time.Since(fi.ModTime()) > ttl {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(cacheFile)
+	if err != nil {
+		return nil, false
+	}
+	versions := []string{}
+	// An empty list can only have been cached from an error page
+	if err := json.Unmarshal(data, &versions); err != nil || len(versions) == 0 {
+		return nil, false
+	}
+	return versions, true
+}
+
+// writeVersionsCache stores versions in cacheFile; failures are not fatal as
+// the cache is only an optimization
+func writeVersionsCache(cacheFile string, versions []string) {
+	data, err := json.Marshal(versions)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(cacheFile), 0755); err != nil {
+		return
+	}
+	ioutil.WriteFile(cacheFile, data, 0644)
+}
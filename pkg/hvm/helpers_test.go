@@ -0,0 +1,187 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package hvm
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	testSumA = "0a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9"
+	testSumB = "f9e8d7c6b5a4039281706f5e4d3c2b1af9e8d7c6b5a4039281706f5e4d3c2b1a"
+)
+
+func TestParseSHA256SUMS(t *testing.T) {
+	cases := []struct {
+		name    string
+		data    string
+		binary  string
+		version string
+		want    map[string]string
+		err     string
+	}{
+		{
+			name:    "modern format",
+			data:    testSumA + "  vault_1.0.2_linux_amd64.zip\n" + testSumB + "  vault_1.0.2_darwin_amd64.zip\n",
+			binary:  Vault,
+			version: "1.0.2",
+			want: map[string]string{
+				"vault_1.0.2_linux_amd64.zip":  testSumA,
+				"vault_1.0.2_darwin_amd64.zip": testSumB,
+			},
+		},
+		{
+			name:    "nomad dot slash names",
+			data:    testSumA + "  ./nomad_0.8.0_linux_amd64.zip\n",
+			binary:  Nomad,
+			version: "0.8.0",
+			want:    map[string]string{"nomad_0.8.0_linux_amd64.zip": testSumA},
+		},
+		{
+			name:    "nomad before dot slash names",
+			data:    testSumA + "  nomad_0.6.3_linux_amd64.zip\n",
+			binary:  Nomad,
+			version: "0.6.3",
+			want:    map[string]string{"nomad_0.6.3_linux_amd64.zip": testSumA},
+		},
+		{
+			name:    "nomad invalid version",
+			data:    testSumA + "  ./nomad_0.8.0_linux_amd64.zip\n",
+			binary:  Nomad,
+			version: "not-a-version",
+			err:     "cannot determine Nomad comparison version",
+		},
+		{
+			name:    "blank lines",
+			data:    "\n" + testSumA + "  consul_1.4.0_linux_amd64.zip\n\n   \n",
+			binary:  Consul,
+			version: "1.4.0",
+			want:    map[string]string{"consul_1.4.0_linux_amd64.zip": testSumA},
+		},
+		{
+			name:    "missing filename",
+			data:    testSumA + "\n",
+			binary:  Consul,
+			version: "1.4.0",
+			err:     "malformed SHA256SUMS line 1",
+		},
+		{
+			name:    "short sum",
+			data:    testSumA + "  consul_1.4.0_linux_amd64.zip\nabc123  consul_1.4.0_darwin_amd64.zip\n",
+			binary:  Consul,
+			version: "1.4.0",
+			err:     "malformed SHA256SUMS line 2",
+		},
+		{
+			name:    "non hex sum",
+			data:    strings.Repeat("z", 64) + "  consul_1.4.0_linux_amd64.zip\n",
+			binary:  Consul,
+			version: "1.4.0",
+			err:     "malformed SHA256SUMS line 1",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ParseSHA256SUMS([]byte(c.data), c.binary, c.version)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("expected error containing %q, got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("expected %d sums, got %d: %v", len(c.want), len(got), got)
+			}
+			for filename, sum := range c.want {
+				if got[filename] != sum {
+					t.Errorf("expected %s for %s, got %q", sum, filename, got[filename])
+				}
+			}
+		})
+	}
+}
+
+func TestChecksumFor(t *testing.T) {
+	fileSha, err := ParseSHA256SUMS([]byte(testSumA+"  vault_1.0.2_linux_amd64.zip\n"), Vault, "1.0.2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cases := []struct {
+		name     string
+		filename string
+		want     string
+		err      bool
+	}{
+		{name: "listed", filename: "vault_1.0.2_linux_amd64.zip", want: testSumA},
+		{name: "binary not found", filename: "consul_1.0.2_linux_amd64.zip", err: true},
+		{name: "version not found", filename: "vault_1.0.3_linux_amd64.zip", err: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ChecksumFor(fileSha, c.filename)
+			if c.err {
+				if err == nil || !strings.Contains(err.Error(), c.filename) {
+					t.Fatalf("expected error naming %s, got %v", c.filename, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != c.want {
+				t.Errorf("expected %s, got %s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeArch(t *testing.T) {
+	cases := []struct {
+		arch string
+		want string
+	}{
+		{"x86_64", "amd64"},
+		{"x64", "amd64"},
+		{"amd64", "amd64"},
+		{"aarch64", "arm64"},
+		{"arm64", "arm64"},
+		{"i386", "386"},
+		{"i686", "386"},
+		{"ppc64le", "ppc64le"},
+		{"X86_64", "amd64"},
+		{"AArch64", "arm64"},
+		{"I686", "386"},
+	}
+	for _, c := range cases {
+		if got := NormalizeArch(c.arch); got != c.want {
+			t.Errorf("NormalizeArch(%q): expected %q, got %q", c.arch, c.want, got)
+		}
+	}
+}
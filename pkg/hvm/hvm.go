@@ -0,0 +1,240 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package hvm installs and resolves versions of HashiCorp binaries; it is what
+// the hvm commands are built on, and lets other Go programs embed the same
+// version lookups and installs. Nothing in this package prints or exits: every
+// failure is returned as an error, those with a known cause as an ExitError.
+package hvm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/go-homedir"
+)
+
+// Settings configure this package; start from DefaultSettings and change what
+// is needed, as a zero duration turns the corresponding behavior off
+type Settings struct {
+	// Home is the data directory binaries are installed into; when empty it
+	// is DataDir of the user home directory
+	Home string
+
+	// ConfigDir is the directory binaries.yaml is read from; when empty it is
+	// ConfigDir of the user home directory
+	ConfigDir string
+
+	// CacheTTL is how long release version lists are cached on disk; 0
+	// disables the cache
+	CacheTTL time.Duration
+
+	// DisableCheckpoint guarantees the Checkpoint API is never contacted
+	DisableCheckpoint bool
+
+	// DownloadTimeout bounds a single archive download; 0 leaves downloads
+	// unbounded
+	DownloadTimeout time.Duration
+
+	// DownloadStallTimeout is how long a download may receive nothing before
+	// it is abandoned and resumed; 0 never abandons one
+	DownloadStallTimeout time.Duration
+
+	// MetadataTimeout bounds version lookups and other small requests
+	MetadataTimeout time.Duration
+
+	// MirrorToken, or else MirrorUsername and MirrorPassword, authenticate
+	// to a private release mirror, which is sent MirrorHeaders as well
+	MirrorToken    string
+	MirrorUsername string
+	MirrorPassword string
+	MirrorHeaders  map[string]string
+
+	// CABundle is the path of a PEM file of the certificate authorities to
+	// verify TLS connections against instead of the system roots, such as
+	// that of a TLS intercepting proxy
+	CABundle string
+
+	// TLSPins are base64 encoded SHA-256 hashes of public keys, one of which
+	// must occur in the certificate chain of every release site connected to
+	TLSPins []string
+
+	// InsecureSkipVerify skips the verification of TLS certificates, leaving
+	// only checksums fetched over the same connections to protect downloads
+	InsecureSkipVerify bool
+
+	// Offline guarantees the network is never used: anything which would
+	// need it, such as looking up versions or downloading, fails with an
+	// error wrapping ErrOffline instead
+	Offline bool
+
+	// VersionSource is where latest versions are looked up, which is either
+	// releases (the default) or checkpoint
+	VersionSource string
+
+	// Logger receives diagnostic logging; nil discards it
+	Logger hclog.Logger
+
+	// Warnings receives problems worth telling a person about which do not
+	// stop anything, such as an invalid entry in binaries.yaml; nil discards
+	// them
+	Warnings io.Writer
+}
+
+// DefaultSettings returns the settings used until Configure is called
+func DefaultSettings() Settings {
+	return Settings{
+		CacheTTL:             time.Hour,
+		DownloadTimeout:      30 * time.Minute,
+		DownloadStallTimeout: time.Minute,
+		MetadataTimeout:      30 * time.Second,
+		VersionSource:        "releases",
+		Logger:               hclog.NewNullLogger(),
+		Warnings:             ioutil.Discard,
+	}
+}
+
+var (
+	settingsMu sync.RWMutex
+	settings   = DefaultSettings()
+)
+
+// Configure replaces the settings of this package; it should be called before
+// anything else in the package, since the registry and HTTP clients are built
+// from the settings in effect when they are first used
+func Configure(s Settings) {
+	if s.Logger == nil {
+		s.Logger = hclog.NewNullLogger()
+	}
+	if s.Warnings == nil {
+		s.Warnings = ioutil.Discard
+	}
+	settingsMu.Lock()
+	settings = s
+	settingsMu.Unlock()
+	resetRegistry()
+	resetHTTPClients()
+}
+
+// currentSettings returns the settings in effect
+func currentSettings() Settings {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return settings
+}
+
+// logger returns the configured logger
+func logger() hclog.Logger {
+	return currentSettings().Logger
+}
+
+// warn writes a warning to the configured Warnings writer
+func warn(format string, a ...interface{}) {
+	fmt.Fprintln(currentSettings().Warnings, fmt.Sprintf("Warning: "+format, a...))
+}
+
+// ErrRootHome indicates that the user home directory is the root directory, as
+// for some daemons and minimal containers, where hvm would otherwise keep its
+// data in /.hvm and link binaries into /bin
+var ErrRootHome = errors.New("the home directory is /; set HOME to another directory, or give hvm a directory of its own with --home or HVM_HOME")
+
+// UserHome returns the user home directory without any trailing separator, or
+// ErrRootHome when it is the root directory
+func UserHome() (string, error) {
+	userHome, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	userHome = filepath.Clean(userHome)
+	if userHome == string(filepath.Separator) {
+		return userHome, ErrRootHome
+	}
+	return userHome, nil
+}
+
+// DataDir returns the default data directory for the user home directory userHome,
+// which is $XDG_DATA_HOME/hvm when XDG_DATA_HOME is set, unless only a legacy
+// $HOME/.hvm exists, which keeps working so existing installations are not broken
+func DataDir(userHome string) string {
+	legacy := filepath.Join(userHome, ".hvm")
+	return xdgDir("XDG_DATA_HOME", legacy, legacy)
+}
+
+// ConfigDir returns the default directory holding the configuration and alias
+// files for the user home directory userHome, which is $XDG_CONFIG_HOME/hvm when
+// XDG_CONFIG_HOME is set, unless only a legacy $HOME/.hvm/hvm.yaml exists
+func ConfigDir(userHome string) string {
+	legacy := filepath.Join(userHome, ".hvm")
+	return xdgDir("XDG_CONFIG_HOME", legacy, fmt.Sprintf("%s/hvm.yaml", legacy))
+}
+
+// dataDir returns the configured data directory
+func dataDir() (string, error) {
+	if home := currentSettings().Home; home != "" {
+		return home, nil
+	}
+	userHome, err := UserHome()
+	if err != nil {
+		return "", fmt.Errorf("Unable to determine user home directory; error: %v", err)
+	}
+	return DataDir(userHome), nil
+}
+
+// configDir returns the configured configuration directory
+func configDir() (string, error) {
+	if dir := currentSettings().ConfigDir; dir != "" {
+		return dir, nil
+	}
+	userHome, err := UserHome()
+	if err != nil {
+		return "", fmt.Errorf("cannot access home directory with error: %v", err)
+	}
+	return ConfigDir(userHome), nil
+}
+
+// xdgDir returns the hvm directory under the base directory named by the
+// environment variable env, falling back to legacy when the variable is unset
+// or when legacyMarker exists but the XDG directory does not yet
+func xdgDir(env string, legacy string, legacyMarker string) string {
+	base := os.Getenv(env)
+	if base == "" {
+		return legacy
+	}
+	dir := filepath.Join(base, "hvm")
+	if _, err := os.Stat(dir); err == nil {
+		return dir
+	}
+	if _, err := os.Stat(legacyMarker); err == nil {
+		return legacy
+	}
+	return dir
+}
@@ -0,0 +1,652 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package hvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/hashicorp/go-getter"
+)
+
+// InstallOptions describe the binary version for Install to install
+type InstallOptions struct {
+	// Binary is the name of a supported binary
+	Binary string
+
+	// Version is the version to install; when empty the latest version is
+	Version string
+
+	// OS and Arch are the platform to install for; when empty they are those
+	// of the host
+	OS   string
+	Arch string
+
+	// Source is a local path or URL to install the release archive from
+	// instead of the releases site, with SourceChecksum optionally giving
+	// its SHA-256 sum
+	Source         string
+	SourceChecksum string
+
+	// DryRun resolves everything an install needs, returning it with the
+	// status planned, without downloading or changing anything
+	DryRun bool
+
+	// Force replaces any existing installation of the version, once the
+	// new one is downloaded and verified
+	Force bool
+
+	// FollowSymlinks installs into a version directory which is a symbolic
+	// link rather than refusing to
+	FollowSymlinks bool
+
+	// Global makes the version directory readable by every user, as it must
+	// be for a version linked into a system directory
+	Global bool
+
+	// KeepArchive keeps the verified release archive in ArchivesDir
+	KeepArchive bool
+
+	// Progress receives the progress of the download when set
+	Progress getter.ProgressTracker
+}
+
+// InstallResult is the outcome of an install, which hvm install --json prints
+type InstallResult struct {
+	Binary      string `json:"binary"`
+	Version     string `json:"version"`
+	OS          string `json:"os"`
+	Arch        string `json:"arch"`
+	InstallPath string `json:"install_path"`
+	SHA256      string `json:"sha256"`
+	Status      string `json:"status"`
+	Active      bool   `json:"active"`
+	Downloaded  int64  `json:"downloaded_bytes"`
+	Error       string `json:"error,omitempty"`
+
+	// URL is where the archive is, or would be for a dry run, downloaded from
+	URL string `json:"-"`
+
+	// Warnings are problems which did not fail the install, such as an
+	// archive which could not be kept
+	Warnings []string `json:"-"`
+}
+
+// Install downloads, verifies and extracts a binary version into the data
+// directory, resuming any download an earlier attempt left unfinished; a
+// failed install leaves no trace of the version behind
+func Install(ctx context.Context, o *InstallOptions) (*InstallResult, error) {
+	opts := *o
+	if opts.OS == "" {
+		opts.OS = runtime.GOOS
+	}
+	if opts.Arch == "" {
+		opts.Arch = runtime.GOARCH
+	}
+	opts.Arch = NormalizeArch(opts.Arch)
+	hvmHome, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Force && opts.Version == "" {
+		return nil, fmt.Errorf("Cannot force install %s without a version", opts.Binary)
+	}
+	if opts.Force && !opts.DryRun {
+		// The version is known up front, so the lock is held for the whole
+		// of a forced install, from before anything is staged until the swap
+		unlock, err := AcquireLock(hvmHome, installLockName(opts.Binary, opts.Version, opts.OS, opts.Arch))
+		if err != nil {
+			logger().Error("install", "lock-error", err.Error())
+			return nil, err
+		}
+		defer unlock()
+	}
+	return installBinary(ctx, hvmHome, &opts)
+}
+
+// installLockName returns the name of the lock which keeps concurrent installs
+// of version v of binary b for a platform out of each other's way
+func installLockName(b string, v string, binaryOS string, binaryArch string) string {
+	return fmt.Sprintf("%s-%s", b, PlatformVersion(v, binaryOS, binaryArch))
+}
+
+// stageVersion creates the staging directory which a forced install of the
+// version directory versionPath is made in; it is next to versionPath so that
+// it can be renamed into place, and hidden from version listings by its name
+func stageVersion(versionPath string, mode os.FileMode) (string, error) {
+	dir := filepath.Dir(versionPath)
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return "", fmt.Errorf("Cannot create directory %s with error: %v", dir, err)
+	}
+	// The install lock is held, so any staging directory left here is from
+	// an earlier install which was cut short
+	stagingPath := fmt.Sprintf("%s/.%s.staging", dir, filepath.Base(versionPath))
+	if err := os.RemoveAll(stagingPath); err != nil {
+		return "", fmt.Errorf("Cannot remove %s with error: %v", stagingPath, err)
+	}
+	if err := os.Mkdir(stagingPath, mode); err != nil {
+		return "", fmt.Errorf("Cannot create directory %s with error: %v", stagingPath, err)
+	}
+	return stagingPath, nil
+}
+
+// swapVersion replaces the version directory versionPath with the complete
+// staging directory stagingPath; any existing installation is moved aside
+// first, restored if the rename fails, and removed only once it succeeds
+func swapVersion(stagingPath string, versionPath string) error {
+	oldPath := ""
+	if _, err := os.Lstat(versionPath); err == nil {
+		oldPath = stagingPath + ".old"
+		if err := os.Rename(versionPath, oldPath); err != nil {
+			return fmt.Errorf("Cannot move %s aside with error: %v", versionPath, err)
+		}
+	}
+	if err := os.Rename(stagingPath, versionPath); err != nil {
+		if oldPath != "" {
+			os.Rename(oldPath, versionPath)
+		}
+		return fmt.Errorf("Cannot move %s into place with error: %v", versionPath, err)
+	}
+	if oldPath != "" {
+		if err := os.RemoveAll(oldPath); err != nil {
+			logger().Warn("install", "remove-old-version-error", err.Error(), "path", oldPath)
+		}
+	}
+	return nil
+}
+
+// DownloadURLs are the locations hvm downloads a release archive from
+type DownloadURLs struct {
+	SHA256SUMS string
+	SumsData   []byte
+	Archive    string
+	Checksum   string
+	URL        string
+}
+
+// ReleaseDownloadURLs returns the SHA256SUMS URL, archive name, published
+// checksum and checksummed download URL of version v of binary b for a platform
+func ReleaseDownloadURLs(ctx context.Context, b string, v string, binaryOS string, binaryArch string) (*DownloadURLs, error) {
+	spec, ok := LookupBinary(b)
+	if !ok {
+		return nil, unsupportedError(fmt.Errorf("%s is not a supported binary", b))
+	}
+	pkgFilename, err := spec.ArchiveName(v, binaryOS, binaryArch)
+	if err != nil {
+		return nil, err
+	}
+	shaFilename, err := spec.ChecksumsName(v)
+	if err != nil {
+		return nil, err
+	}
+	// Store <binary>_<version>_SHA256SUMS file obtained from
+	// https://releases.hashicorp.com/<binary>/<version>/<binary>_<version>_SHA256SUMS
+	// in map for comparison
+	urls := &DownloadURLs{
+		SHA256SUMS: fmt.Sprintf("%s/%s", spec.VersionURL(v), shaFilename),
+		Archive:    pkgFilename,
+	}
+	binarySha, err := FetchData(ctx, urls.SHA256SUMS)
+	if errors.Is(err, ErrNotFound) {
+		return nil, &ExitError{
+			Code: ExitInvalidVersion,
+			Err:  fmt.Errorf("%s version %s is not published at %s; use hvm versions %s to list available versions", b, v, spec.VersionURL(v), b),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	fileSha, err := ParseSHA256SUMS(binarySha, b, v)
+	if err != nil {
+		return nil, err
+	}
+	checkSha, err := ChecksumFor(fileSha, pkgFilename)
+	if err != nil {
+		return nil, err
+	}
+	urls.SumsData = binarySha
+	urls.Checksum = checkSha
+	urls.URL = fmt.Sprintf("%s/%s?checksum=sha256:%s", spec.VersionURL(v), pkgFilename, urls.Checksum)
+	return urls, nil
+}
+
+// ArchivesDir returns the directory in the data directory hvmHome which release
+// archives are kept in with the KeepArchive install option
+func ArchivesDir(hvmHome string) string {
+	return fmt.Sprintf("%s/archives", hvmHome)
+}
+
+// keepArchive moves the verified archive at archivePath into the archives
+// directory, along with the SHA256SUMS file it was verified against when it was
+// downloaded from a release
+func keepArchive(hvmHome string, archivePath string, urls *DownloadURLs) error {
+	dir := ArchivesDir(hvmHome)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if urls != nil && len(urls.SumsData) > 0 {
+		sumsPath := fmt.Sprintf("%s/%s", dir, path.Base(urls.SHA256SUMS))
+		if err := ioutil.WriteFile(sumsPath, urls.SumsData, 0644); err != nil {
+			return err
+		}
+	}
+	return os.Rename(archivePath, fmt.Sprintf("%s/%s", dir, filepath.Base(archivePath)))
+}
+
+// KeptArchive returns the paths of the archive and SHA256SUMS file kept for
+// version v of the binary described by spec on a platform, or empty strings when
+// either of them was not kept
+func KeptArchive(hvmHome string, spec *BinarySpec, v string, binaryOS string, binaryArch string) (string, string) {
+	pkgFilename, err := spec.ArchiveName(v, binaryOS, binaryArch)
+	if err != nil {
+		return "", ""
+	}
+	shaFilename, err := spec.ChecksumsName(v)
+	if err != nil {
+		return "", ""
+	}
+	archivePath := fmt.Sprintf("%s/%s", ArchivesDir(hvmHome), pkgFilename)
+	sumsPath := fmt.Sprintf("%s/%s", ArchivesDir(hvmHome), shaFilename)
+	for _, p := range []string{archivePath, sumsPath} {
+		if _, err := os.Stat(p); err != nil {
+			return "", ""
+		}
+	}
+	return archivePath, sumsPath
+}
+
+// sourceChecksum returns the checksum of pkgFilename listed in the SHA256SUMS
+// file of version v next to the local archive source, or an empty string when
+// source is not a local path or there is no such file
+func sourceChecksum(spec *BinarySpec, source string, pkgFilename string, v string) string {
+	if u, err := url.Parse(source); err != nil || u.Scheme != "" {
+		return ""
+	}
+	shaFilename, err := spec.ChecksumsName(v)
+	if err != nil {
+		return ""
+	}
+	data, err := ioutil.ReadFile(filepath.Join(filepath.Dir(source), shaFilename))
+	if err != nil {
+		return ""
+	}
+	fileSha, err := ParseSHA256SUMS(data, spec.Name, v)
+	if err != nil {
+		return ""
+	}
+	return fileSha[pkgFilename]
+}
+
+// isLocalSource returns true if source is a path on this machine rather than a
+// URL; no source at all means a download from the release site
+func isLocalSource(source string) bool {
+	if source == "" {
+		return false
+	}
+	u, err := url.Parse(source)
+	return err == nil && (u.Scheme == "" || u.Scheme == "file")
+}
+
+// downloadArchive downloads the archive at fullURL to archivePath; releases and
+// other plain http(s) URLs are downloaded resumably while anything else, such
+// as a local path or other go-getter URL given as a source, uses go-getter
+func downloadArchive(ctx context.Context, archivePath string, fullURL string, progress getter.ProgressTracker) error {
+	ctx, cancel := DownloadContext(ctx)
+	defer cancel()
+	u, err := url.Parse(fullURL)
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		// The archive checksum is verified after the download completes
+		q := u.Query()
+		q.Del("checksum")
+		u.RawQuery = q.Encode()
+		return ResumableDownload(ctx, archivePath, u.String(), progress)
+	}
+	opts := []getter.ClientOption{getter.WithContext(ctx), WithHTTPGetter(mirrorHeadersFor(fullURL))}
+	if progress != nil {
+		opts = append(opts, getter.WithProgress(progress))
+	}
+	return getter.GetFile(archivePath, withQuery(fullURL, "archive", "false"), opts...)
+}
+
+// checkVersionDir returns true if the version directory targetPath is a
+// symbolic link, which is an error unless follow is set because writes into
+// it would land wherever it happens to point
+func checkVersionDir(targetPath string, follow bool) (bool, error) {
+	fi, err := os.Lstat(targetPath)
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return false, nil
+	}
+	if !follow {
+		dest, _ := os.Readlink(targetPath)
+		return true, fmt.Errorf("%s is a symbolic link to %s; remove it, or use --follow-symlinks to install into its target", targetPath, dest)
+	}
+	return true, nil
+}
+
+// downloadError translates a download error into a message which tells a
+// missing release, refused credentials and a corrupt archive apart from a
+// genuine network failure; only the last is reported as a network error
+func downloadError(err error, o *InstallOptions, v string) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return networkError(fmt.Errorf("Download of %s version %s timed out after %s; run install again to resume it, or raise download_timeout", o.Binary, v, currentSettings().DownloadTimeout))
+	}
+	var cerr *getter.ChecksumError
+	if errors.As(err, &cerr) {
+		return fmt.Errorf("Cannot install %s version %s; the checksum of the downloaded archive does not match the published one, so it may be corrupt or tampered with", o.Binary, v)
+	}
+	switch code := ResponseCode(err); {
+	case code == http.StatusNotFound:
+		return &ExitError{
+			Code: ExitInvalidVersion,
+			Err:  fmt.Errorf("%s version %s is not published for %s/%s; use hvm versions %s to list available versions", o.Binary, v, o.OS, o.Arch, o.Binary),
+		}
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return accessDeniedError(fmt.Sprintf("download %s version %s", o.Binary, v), fmt.Sprintf("response code %d", code))
+	case code >= 500:
+		return networkError(fmt.Errorf("Cannot download %s version %s; the release site responded with code %d, so try again later", o.Binary, v, code))
+	}
+	var e *ExitError
+	var uerr *url.Error
+	var nerr net.Error
+	if (errors.As(err, &e) && e.Code == ExitNetwork) || errors.As(err, &uerr) || errors.As(err, &nerr) {
+		return networkError(fmt.Errorf("Cannot download %s version %s with error: %v", o.Binary, v, err))
+	}
+	return fmt.Errorf("Cannot download %s version %s with error: %v", o.Binary, v, err)
+}
+
+// ResponseCode returns the HTTP status code of a download which failed with a
+// bad response code, as reported by both go-getter and ResumableDownload, or 0
+// for any other error
+func ResponseCode(err error) int {
+	var code int
+	if _, scanErr := fmt.Sscanf(err.Error(), "bad response code: %d", &code); scanErr != nil {
+		return 0
+	}
+	return code
+}
+
+// installBinary has entirely too much going on in it right now!
+// some of this needs to possibly be refactored into helpers
+func installBinary(ctx context.Context, hvmHome string, o *InstallOptions) (result *InstallResult, err error) {
+	b := o.Binary
+	v := o.Version
+	logger := logger()
+	logger.Debug("install", "f-install-binary", "start", "with-binary", b)
+	// Report an interrupt plainly rather than as whichever step it cut short
+	defer func() {
+		if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+			logger.Warn("install", "interrupted", err.Error())
+			err = &ExitError{Code: ExitInterrupted, Err: errors.New("interrupted; any partial download is resumed by the next install")}
+		}
+	}()
+	if b == "" {
+		logger.Error("install", "unknown-binary", "GURU DEDICATION")
+		return nil, fmt.Errorf("install: unknown binary. GURU DEDICATION")
+	}
+	if !IsSupported(b) {
+		logger.Warn("install", "binary", b, "unsupported-binary", "not in CheckPoint API")
+		return nil, unsupportedError(fmt.Errorf("Cannot install %q; it is not a supported binary; for a list of supported binaries, use hvm install --help", b))
+	}
+	if v == "" {
+		logger.Debug("install", "f-install-binary", "blank-version", "binary", b)
+		latestBinaryVersion, err := GetLatestVersion(ctx, b)
+		if err != nil {
+			logger.Error("install", "get-latest-version-fail", "error", err.Error())
+			return nil, err
+		}
+		logger.Debug("install", "get-latest-version", "inner", "got-version", latestBinaryVersion)
+		v = latestBinaryVersion
+	}
+	logger.Info("install", "install binary candidate", "final", "binary", b, "desired-version", v)
+	// Catch platforms a binary is not published for here rather than
+	// with an opaque 404 from the download; a local source may be anything
+	if o.Source == "" {
+		if err := ValidateBinaryPlatform(b, o.OS, o.Arch); err != nil {
+			logger.Error("install", "unsupported-platform", err.Error())
+			return nil, unsupportedError(err)
+		}
+	}
+	if currentSettings().Offline && !isLocalSource(o.Source) {
+		logger.Error("install", "offline", "true", "binary", b, "version", v)
+		return nil, offlineError(fmt.Sprintf("install %s version %s, which needs a download", b, v))
+	}
+	targetPath := fmt.Sprintf("%s/%s/%s", hvmHome, b, PlatformVersion(v, o.OS, o.Arch))
+	if !o.DryRun && !o.Force {
+		// Keep concurrent installs of the same version out of each other's way;
+		// Install already holds the lock for a forced install
+		unlock, err := AcquireLock(hvmHome, installLockName(b, v, o.OS, o.Arch))
+		if err != nil {
+			logger.Error("install", "lock-error", err.Error())
+			return nil, err
+		}
+		defer unlock()
+	}
+	var pkgFilename, checkSha, fullURL string
+	var urls *DownloadURLs
+	if o.Source != "" {
+		spec, _ := LookupBinary(b)
+		pkgFilename, err = spec.ArchiveName(v, o.OS, o.Arch)
+		if err != nil {
+			logger.Error("install", "archive-name-error", err.Error())
+			return nil, err
+		}
+		// Install from a local path or URL given by the user; the checksum is
+		// optional, but is taken from a SHA256SUMS file alongside a local
+		// archive, such as one kept with KeepArchive, when there is one
+		checkSha = o.SourceChecksum
+		if checkSha == "" {
+			checkSha = sourceChecksum(spec, o.Source, pkgFilename, v)
+		}
+		logger.Debug("install", "source", o.Source, "checksum", checkSha)
+		fullURL = o.Source
+		if checkSha != "" {
+			fullURL = withQuery(fullURL, "checksum", fmt.Sprintf("sha256:%s", checkSha))
+		}
+	} else {
+		urls, err = ReleaseDownloadURLs(ctx, b, v, o.OS, o.Arch)
+		if err != nil {
+			logger.Error("install", "download-url-error", err.Error())
+			return nil, err
+		}
+		logger.Debug("install", "sha256sums-file-url", urls.SHA256SUMS)
+		pkgFilename = urls.Archive
+		checkSha = urls.Checksum
+		fullURL = urls.URL
+	}
+	installPath := fmt.Sprintf("%s/%s", targetPath, b)
+	logger.Debug("install", "valid-binary", "true", "full-url", fullURL, "install-path", installPath)
+	result = &InstallResult{
+		Binary:      b,
+		Version:     v,
+		OS:          o.OS,
+		Arch:        o.Arch,
+		InstallPath: installPath,
+		SHA256:      checkSha,
+		URL:         fullURL,
+	}
+	if o.DryRun {
+		logger.Info("install", "dry-run", "true", "binary", b, "version", v, "full-url", fullURL, "install-path", installPath)
+		result.Status = "planned"
+		return result, nil
+	}
+	linked, err := checkVersionDir(targetPath, o.FollowSymlinks)
+	if err != nil {
+		logger.Error("install", "symlinked-version-dir", err.Error())
+		return nil, err
+	}
+	// A version linked globally must be reachable by every user
+	var mode os.FileMode = 0770
+	if o.Global {
+		mode = 0755
+	}
+	// A forced install is made in a staging directory which replaces the
+	// version directory, or the target of a linked one, only once it is
+	// complete, so that a failed download leaves the existing one working
+	workPath := targetPath
+	swapPath := targetPath
+	if o.Force {
+		if linked {
+			if swapPath, err = filepath.EvalSymlinks(targetPath); err != nil {
+				logger.Error("install", "resolve-version-dir-error", err.Error())
+				return nil, fmt.Errorf("Cannot resolve %s with error: %v", targetPath, err)
+			}
+		}
+		if workPath, err = stageVersion(swapPath, mode); err != nil {
+			logger.Error("install", "staging-error", err.Error())
+			return nil, err
+		}
+		logger.Debug("install", "status", "staging", "staging-path", workPath)
+		defer func() {
+			os.RemoveAll(workPath)
+			if err != nil {
+				// Also remove the binary directory if it was created for staging
+				os.Remove(filepath.Dir(targetPath))
+			}
+		}()
+	}
+	workInstallPath := fmt.Sprintf("%s/%s", workPath, b)
+	createdTarget := false
+	if _, err := os.Stat(targetPath); os.IsNotExist(err) && !o.Force {
+		if err := os.MkdirAll(targetPath, mode); err != nil {
+			logger.Error("install", "directory-creation-error", err.Error())
+			return nil, fmt.Errorf("Cannot create directory %s with error: %v", targetPath, err)
+		}
+		createdTarget = true
+	}
+	// A failed install must leave no trace of the version behind
+	defer func() {
+		if createdTarget && err != nil {
+			logger.Debug("install", "status", "cleanup", "target-path", targetPath)
+			os.RemoveAll(targetPath)
+			// Also remove the binary directory if this was its only version
+			os.Remove(filepath.Dir(targetPath))
+		}
+	}()
+	logger.Debug("install", "status", "go-getter", "download-url", fullURL)
+	logger.Debug("install", "status", "go-getter", "install-path", installPath)
+	// Get binary archive from a URL which takes the form of:
+	// 'https://releases.hashicorp.com/<binary>/<version>/<binary>_<version>_<os>_<arch>.zip
+	// The download resumes from where an earlier attempt left off and the
+	// archive is kept intact so that it can be verified below before extraction.
+	// It is downloaded under the cache directory so that a partial download
+	// survives the removal of the version directory when an install fails.
+	downloadDir := fmt.Sprintf("%s/cache/downloads", hvmHome)
+	if err := os.MkdirAll(downloadDir, 0755); err != nil {
+		logger.Error("install", "directory-creation-error", err.Error())
+		return nil, fmt.Errorf("Cannot create directory %s with error: %v", downloadDir, err)
+	}
+	archivePath := fmt.Sprintf("%s/%s", downloadDir, pkgFilename)
+	// Only what this run downloads counts, not what an earlier attempt did
+	var resumedBytes int64
+	if fi, err := os.Stat(archivePath + ".part"); err == nil {
+		resumedBytes = fi.Size()
+	}
+	if err := downloadArchive(ctx, archivePath, fullURL, o.Progress); err != nil {
+		// If the SHA don't match or we hit any issue, then we ain't dancing!
+		logger.Error("install", "download-zip-error", err.Error())
+		return nil, downloadError(err, o, v)
+	}
+	if fi, err := os.Stat(archivePath); err == nil {
+		result.Downloaded = fi.Size() - resumedBytes
+	}
+	// Verify the complete archive, which also covers the resumed parts of it
+	archiveSha, err := FileSHA256(archivePath)
+	if err != nil {
+		logger.Error("install", "hash-zip-error", err.Error())
+		os.Remove(archivePath)
+		return nil, err
+	}
+	if checkSha == "" {
+		logger.Warn("install", "issue", "no-checksum", "source", fullURL, "sha256", archiveSha)
+	} else if archiveSha != checkSha {
+		logger.Error("install", "issue", "checksum-mismatch", "expected", checkSha, "actual", archiveSha)
+		os.Remove(archivePath)
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", pkgFilename, checkSha, archiveSha)
+	}
+	logger.Debug("install", "status", "checksum-verified", "sha256", archiveSha)
+	// A source is extracted according to its own name when that is recognizable
+	archiveName := pkgFilename
+	if o.Source != "" && archiveFormat(o.Source) != "" {
+		archiveName = o.Source
+	}
+	if err := ExtractBinary(archivePath, archiveName, b, workInstallPath); err != nil {
+		logger.Error("install", "extract-zip-error", err.Error())
+		os.Remove(archivePath)
+		return nil, err
+	}
+	if o.KeepArchive {
+		if err := keepArchive(hvmHome, archivePath, urls); err != nil {
+			logger.Warn("install", "keep-archive-error", err.Error())
+			result.Warnings = append(result.Warnings, fmt.Sprintf("cannot keep archive %s with error: %v", pkgFilename, err))
+		}
+	} else if err := os.Remove(archivePath); err != nil {
+		logger.Warn("install", "remove-zip-error", err.Error())
+	}
+	// Ensure the binary is runnable regardless of archive permissions and umask
+	if err := os.Chmod(workInstallPath, 0755); err != nil {
+		logger.Error("install", "chmod-error", err.Error(), "install-path", installPath)
+		return nil, fmt.Errorf("Cannot set executable permissions on %s with error: %v", installPath, err)
+	}
+	logger.Debug("install", "status", "chmod", "install-path", installPath, "mode", "0755")
+	// Record where this version came from so the store is self describing
+	installedSha, err := FileSHA256(workInstallPath)
+	if err != nil {
+		logger.Warn("install", "hash-binary-error", err.Error())
+	}
+	md := &InstallMetadata{
+		Binary:        b,
+		Version:       v,
+		OS:            o.OS,
+		Arch:          o.Arch,
+		InstalledAt:   time.Now().UTC(),
+		SourceURL:     fullURL,
+		ArchiveSHA256: archiveSha,
+		BinarySHA256:  installedSha,
+	}
+	if err := WriteInstallMetadata(workPath, md); err != nil {
+		logger.Warn("install", "metadata-error", err.Error())
+	}
+	if o.Force {
+		if err := swapVersion(workPath, swapPath); err != nil {
+			logger.Error("install", "swap-error", err.Error())
+			return nil, err
+		}
+		logger.Debug("install", "status", "swapped", "version-path", swapPath)
+	}
+	result.SHA256 = archiveSha
+	result.Status = "installed"
+	return result, nil
+}
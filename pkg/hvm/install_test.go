@@ -0,0 +1,302 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package hvm
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testRelease is a vault release served by newReleaseServer
+type testRelease struct {
+	archive []byte
+	sums    string
+	// sumsStatus answers SHA256SUMS requests when it is set
+	sumsStatus int
+}
+
+// newTestRelease returns a release of vault version v for linux/amd64 whose
+// SHA256SUMS lists sum for the archive, or its real sum when sum is empty
+func newTestRelease(t *testing.T, v string, sum string) *testRelease {
+	t.Helper()
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	f, err := z.Create(Vault)
+	if err != nil {
+		t.Fatalf("cannot create archive with error: %v", err)
+	}
+	fmt.Fprintf(f, "#!/bin/sh\necho Vault v%s\n", v)
+	if err := z.Close(); err != nil {
+		t.Fatalf("cannot create archive with error: %v", err)
+	}
+	if sum == "" {
+		h := sha256.Sum256(buf.Bytes())
+		sum = hex.EncodeToString(h[:])
+	}
+	return &testRelease{
+		archive: buf.Bytes(),
+		sums:    fmt.Sprintf("%s  vault_%s_linux_amd64.zip\n", sum, v),
+	}
+}
+
+// newReleaseServer serves releases by version in the layout of the release
+// site, answering archive requests with archiveStatus when it is not 200, and
+// configures a new temporary data directory whose binaries.yaml points vault
+// at the server; it returns the server, the data directory and a function
+// returning the paths requested so far
+func newReleaseServer(t *testing.T, releases map[string]*testRelease, archiveStatus int) (*httptest.Server, string, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	paths := []string{}
+	requested := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, paths...)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/vault/" || r.URL.Path == "/vault" {
+			w.Header().Set("Content-Type", "text/html")
+			for v := range releases {
+				fmt.Fprintf(w, "<a href=\"/vault/%s/\">vault_%s</a>\n", v, v)
+			}
+			return
+		}
+		for v, rel := range releases {
+			switch r.URL.Path {
+			case fmt.Sprintf("/vault/%s/vault_%s_SHA256SUMS", v, v):
+				if rel.sumsStatus != 0 {
+					w.WriteHeader(rel.sumsStatus)
+					return
+				}
+				w.Header().Set("Content-Type", "text/plain")
+				fmt.Fprint(w, rel.sums)
+				return
+			case fmt.Sprintf("/vault/%s/vault_%s_linux_amd64.zip", v, v):
+				if archiveStatus != http.StatusOK {
+					w.WriteHeader(archiveStatus)
+					return
+				}
+				w.Header().Set("Content-Type", "application/zip")
+				w.Write(rel.archive)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	home := t.TempDir()
+	binaries := fmt.Sprintf("binaries:\n  - name: vault\n    release_url: %s\n", srv.URL)
+	if err := ioutil.WriteFile(filepath.Join(home, "binaries.yaml"), []byte(binaries), 0644); err != nil {
+		t.Fatalf("cannot write binaries.yaml with error: %v", err)
+	}
+	s := DefaultSettings()
+	s.Home = home
+	s.ConfigDir = home
+	s.CacheTTL = 0
+	Configure(s)
+	// Versions listed by an earlier test came from another server
+	releaseVersionsMu.Lock()
+	releaseVersionsCache = map[string][]string{}
+	releaseVersionsMu.Unlock()
+	t.Cleanup(func() { Configure(DefaultSettings()) })
+	return srv, home, requested
+}
+
+func TestInstall(t *testing.T) {
+	srv, home, requested := newReleaseServer(t, map[string]*testRelease{"1.0.2": newTestRelease(t, "1.0.2", "")}, http.StatusOK)
+	result, err := Install(context.Background(), &InstallOptions{Binary: Vault, Version: "1.0.2", OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	installPath := filepath.Join(home, Vault, "1.0.2", Vault)
+	if result.InstallPath != installPath {
+		t.Errorf("expected install path %s, got %s", installPath, result.InstallPath)
+	}
+	if result.Status != "installed" {
+		t.Errorf("expected status installed, got %s", result.Status)
+	}
+	data, err := ioutil.ReadFile(installPath)
+	if err != nil {
+		t.Fatalf("cannot read installed binary with error: %v", err)
+	}
+	if !strings.Contains(string(data), "Vault v1.0.2") {
+		t.Errorf("unexpected installed binary contents: %q", data)
+	}
+	fi, err := os.Stat(installPath)
+	if err != nil || fi.Mode()&0111 == 0 {
+		t.Errorf("expected an executable binary at %s", installPath)
+	}
+	wantURL := fmt.Sprintf("%s/vault/1.0.2/vault_1.0.2_linux_amd64.zip?checksum=sha256:%s", srv.URL, result.SHA256)
+	if result.URL != wantURL {
+		t.Errorf("expected URL %s, got %s", wantURL, result.URL)
+	}
+	wantRequests := []string{"/vault/1.0.2/vault_1.0.2_SHA256SUMS", "/vault/1.0.2/vault_1.0.2_linux_amd64.zip"}
+	if got := requested(); strings.Join(got, " ") != strings.Join(wantRequests, " ") {
+		t.Errorf("expected requests %v, got %v", wantRequests, got)
+	}
+}
+
+func TestInstallLatest(t *testing.T) {
+	newReleaseServer(t, map[string]*testRelease{
+		"1.0.1": newTestRelease(t, "1.0.1", ""),
+		"1.0.2": newTestRelease(t, "1.0.2", ""),
+	}, http.StatusOK)
+	result, err := Install(context.Background(), &InstallOptions{Binary: Vault, OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Version != "1.0.2" {
+		t.Errorf("expected the latest version 1.0.2 from the index page, got %s", result.Version)
+	}
+}
+
+func TestInstallForce(t *testing.T) {
+	_, home, _ := newReleaseServer(t, map[string]*testRelease{"1.0.2": newTestRelease(t, "1.0.2", "")}, http.StatusOK)
+	o := &InstallOptions{Binary: Vault, Version: "1.0.2", OS: "linux", Arch: "amd64"}
+	if _, err := Install(context.Background(), o); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	versionPath := filepath.Join(home, Vault, "1.0.2")
+	marker := filepath.Join(versionPath, "marker")
+	if err := ioutil.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatalf("cannot write marker with error: %v", err)
+	}
+	o.Force = true
+	if _, err := Install(context.Background(), o); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be replaced by the forced install", versionPath)
+	}
+	entries, err := ioutil.ReadDir(filepath.Join(home, Vault))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the version directory to be left, got %d entries", len(entries))
+	}
+}
+
+func TestInstallChecksumMismatch(t *testing.T) {
+	_, home, _ := newReleaseServer(t, map[string]*testRelease{"1.0.2": newTestRelease(t, "1.0.2", strings.Repeat("0", 64))}, http.StatusOK)
+	_, err := Install(context.Background(), &InstallOptions{Binary: Vault, Version: "1.0.2", OS: "linux", Arch: "amd64"})
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+	var e *ExitError
+	if errors.As(err, &e) {
+		t.Errorf("expected a plain failure, got exit code %d", e.Code)
+	}
+	if _, err := os.Stat(filepath.Join(home, Vault, "1.0.2")); !os.IsNotExist(err) {
+		t.Errorf("expected no version directory to be left after a failed install")
+	}
+}
+
+func TestInstallResponseCodes(t *testing.T) {
+	cases := []struct {
+		status int
+		code   int
+	}{
+		{http.StatusNotFound, ExitInvalidVersion},
+		{http.StatusForbidden, ExitFailure},
+		{http.StatusUnauthorized, ExitFailure},
+		{http.StatusServiceUnavailable, ExitNetwork},
+	}
+	for _, c := range cases {
+		t.Run(http.StatusText(c.status), func(t *testing.T) {
+			newReleaseServer(t, map[string]*testRelease{"1.0.2": newTestRelease(t, "1.0.2", "")}, c.status)
+			_, err := Install(context.Background(), &InstallOptions{Binary: Vault, Version: "1.0.2", OS: "linux", Arch: "amd64"})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			code := ExitFailure
+			var e *ExitError
+			if errors.As(err, &e) {
+				code = e.Code
+			}
+			if code != c.code {
+				t.Errorf("expected exit code %d, got %d: %v", c.code, code, err)
+			}
+		})
+	}
+}
+
+func TestInstallSHA256SUMSResponseCodes(t *testing.T) {
+	cases := []struct {
+		status int
+		code   int
+	}{
+		{http.StatusNotFound, ExitInvalidVersion},
+		{http.StatusForbidden, ExitFailure},
+		{http.StatusUnauthorized, ExitFailure},
+		{http.StatusInternalServerError, ExitNetwork},
+	}
+	for _, c := range cases {
+		t.Run(http.StatusText(c.status), func(t *testing.T) {
+			rel := newTestRelease(t, "1.0.2", "")
+			rel.sumsStatus = c.status
+			newReleaseServer(t, map[string]*testRelease{"1.0.2": rel}, http.StatusOK)
+			_, err := Install(context.Background(), &InstallOptions{Binary: Vault, Version: "1.0.2", OS: "linux", Arch: "amd64"})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			code := ExitFailure
+			var e *ExitError
+			if errors.As(err, &e) {
+				code = e.Code
+			}
+			if code != c.code {
+				t.Errorf("expected exit code %d, got %d: %v", c.code, code, err)
+			}
+		})
+	}
+}
+
+func TestInstallLatestUnreachable(t *testing.T) {
+	srv, _, _ := newReleaseServer(t, map[string]*testRelease{}, http.StatusOK)
+	srv.Close()
+	_, err := Install(context.Background(), &InstallOptions{Binary: Vault, OS: "linux", Arch: "amd64"})
+	var e *ExitError
+	if !errors.As(err, &e) || e.Code != ExitNetwork {
+		t.Errorf("expected a network error, got %v", err)
+	}
+}
@@ -0,0 +1,408 @@
+// Copyright © 2019 Brian Shumate <brian@brianshumate.com>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package hvm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/hashicorp/go-version"
+	"github.com/spf13/viper"
+)
+
+// defaultArchive is the template for the name of a release archive
+const defaultArchive string = "{{.Name}}_{{.Version}}_{{.OS}}_{{.Arch}}.zip"
+
+// defaultChecksums is the template for the name of a release SHA256SUMS file
+const defaultChecksums string = "{{.Name}}_{{.Version}}_SHA256SUMS"
+
+// defaultTag is the template for the GitHub release tag of a version
+const defaultTag string = "v{{.Version}}"
+
+// GitHubURLBase is the URL base GitHub release assets are downloaded from
+const GitHubURLBase string = "https://github.com"
+
+// BinarySpec describes where and how a supported binary is published
+type BinarySpec struct {
+	// Name of the binary, which is also its path on the releases site
+	Name string `mapstructure:"name"`
+
+	// ReleaseURL is the URL base of the releases site publishing the binary
+	ReleaseURL string `mapstructure:"release_url"`
+
+	// Checkpoint is true if the Checkpoint API knows the binary
+	Checkpoint bool `mapstructure:"checkpoint"`
+
+	// Archive is a text/template for the archive name given the Name,
+	// Version, OS and Arch, in which atLeast .Version "x.y.z" tests for
+	// versions from x.y.z on as publishing conventions change; its suffix, such as .zip, .tar.gz or .dmg,
+	// determines how the archive is extracted
+	Archive string `mapstructure:"archive"`
+
+	// Archives overrides Archive for the platforms it has an entry for,
+	// keyed by os_arch or by os alone
+	Archives map[string]string `mapstructure:"archives"`
+
+	// Checksums is a text/template for the name of the SHA256SUMS file
+	// published alongside the archives given the Name and Version
+	Checksums string `mapstructure:"checksums"`
+
+	// GitHub is the owner/repository of a binary published as GitHub release
+	// assets instead of on a releases site; ReleaseURL is then unused
+	GitHub string `mapstructure:"github"`
+
+	// Tag is a text/template for the GitHub release tag of a Version
+	Tag string `mapstructure:"tag"`
+
+	// Platforms maps each operating system to the architectures the binary
+	// is published for
+	Platforms map[string][]string `mapstructure:"platforms"`
+}
+
+// ArchiveName returns the name of the archive of version v for a platform
+func (s *BinarySpec) ArchiveName(v string, binaryOS string, binaryArch string) (string, error) {
+	archive := s.Archive
+	if a, ok := s.Archives[fmt.Sprintf("%s_%s", binaryOS, binaryArch)]; ok {
+		archive = a
+	} else if a, ok := s.Archives[binaryOS]; ok {
+		archive = a
+	}
+	return s.render("archive", archive, v, binaryOS, binaryArch)
+}
+
+// ChecksumsName returns the name of the SHA256SUMS file of version v
+func (s *BinarySpec) ChecksumsName(v string) (string, error) {
+	return s.render("checksums", s.Checksums, v, "", "")
+}
+
+// render renders the kind of template tmpl for version v of a platform
+func (s *BinarySpec) render(kind string, tmpl string, v string, binaryOS string, binaryArch string) (string, error) {
+	t, err := template.New(s.Name).Funcs(archiveFuncs).Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("Cannot parse %s template for %s with error: %v", kind, s.Name, err)
+	}
+	var name bytes.Buffer
+	data := map[string]string{"Name": s.Name, "Version": v, "OS": binaryOS, "Arch": binaryArch}
+	if err := t.Execute(&name, data); err != nil {
+		return "", fmt.Errorf("Cannot render %s template for %s with error: %v", kind, s.Name, err)
+	}
+	return name.String(), nil
+}
+
+// TagVersion returns the version named by a GitHub release tag, or false if
+// the tag does not match the Tag template
+func (s *BinarySpec) TagVersion(tag string) (string, bool) {
+	// Rendering a marker in place of the version splits the template into
+	// the prefix and suffix surrounding the version in every tag
+	marked, err := s.render("tag", s.Tag, "\x00", "", "")
+	if err != nil {
+		return "", false
+	}
+	parts := strings.SplitN(marked, "\x00", 2)
+	if len(parts) != 2 || !strings.HasPrefix(tag, parts[0]) || !strings.HasSuffix(tag, parts[1]) || len(tag) <= len(parts[0])+len(parts[1]) {
+		return "", false
+	}
+	return tag[len(parts[0]) : len(tag)-len(parts[1])], true
+}
+
+// archiveFuncs are the functions available to archive name templates
+var archiveFuncs = template.FuncMap{
+	"atLeast": func(v string, min string) bool {
+		cv, err := version.NewVersion(v)
+		if err != nil {
+			return false
+		}
+		mv, err := version.NewVersion(min)
+		if err != nil {
+			return false
+		}
+		return cv.GreaterThanOrEqual(mv)
+	},
+}
+
+// VersionURL returns the URL of the directory holding the release of version v
+func (s *BinarySpec) VersionURL(v string) string {
+	if s.GitHub != "" {
+		// The tag template is checked when the registry is loaded
+		tag, _ := s.render("tag", s.Tag, v, "", "")
+		return fmt.Sprintf("%s/%s/releases/download/%s", GitHubURLBase, s.GitHub, tag)
+	}
+	return fmt.Sprintf("%s/%s/%s", s.ReleaseURL, s.Name, v)
+}
+
+// builtinBinaries are the binaries hvm supports without a binaries.yaml
+var builtinBinaries = []BinarySpec{
+	{
+		Name:       Consul,
+		ReleaseURL: ReleaseURLBase,
+		Checkpoint: true,
+		Archive:    defaultArchive,
+		Platforms: map[string][]string{
+			"darwin":  {"amd64", "arm64"},
+			"freebsd": {"386", "amd64", "arm"},
+			"linux":   {"386", "amd64", "arm", "arm64"},
+			"openbsd": {"386", "amd64"},
+			"solaris": {"amd64"},
+			"windows": {"386", "amd64"},
+		},
+	},
+	{
+		Name:       ConsulK8s,
+		ReleaseURL: ReleaseURLBase,
+		Checkpoint: false,
+		Archive:    defaultArchive,
+		Platforms: map[string][]string{
+			"darwin":  {"amd64", "arm64"},
+			"linux":   {"386", "amd64", "arm", "arm64"},
+			"windows": {"386", "amd64"},
+		},
+	},
+	{
+		Name:       Nomad,
+		ReleaseURL: ReleaseURLBase,
+		Checkpoint: true,
+		Archive:    defaultArchive,
+		Platforms: map[string][]string{
+			"darwin":  {"amd64", "arm64"},
+			"linux":   {"386", "amd64", "arm", "arm64"},
+			"windows": {"386", "amd64"},
+		},
+	},
+	{
+		Name:       NomadAutoscaler,
+		ReleaseURL: ReleaseURLBase,
+		Checkpoint: false,
+		Archive:    defaultArchive,
+		Platforms: map[string][]string{
+			"darwin":  {"amd64", "arm64"},
+			"linux":   {"amd64", "arm64"},
+			"windows": {"amd64"},
+		},
+	},
+	{
+		Name:       Packer,
+		ReleaseURL: ReleaseURLBase,
+		Checkpoint: true,
+		Archive:    defaultArchive,
+		Platforms: map[string][]string{
+			"darwin":  {"amd64", "arm64"},
+			"freebsd": {"386", "amd64", "arm"},
+			"linux":   {"386", "amd64", "arm", "arm64"},
+			"openbsd": {"386", "amd64"},
+			"solaris": {"amd64"},
+			"windows": {"386", "amd64"},
+		},
+	},
+	{
+		Name:       Serf,
+		ReleaseURL: ReleaseURLBase,
+		Checkpoint: false,
+		Archive:    defaultArchive,
+		Platforms: map[string][]string{
+			"darwin":  {"amd64"},
+			"freebsd": {"386", "amd64", "arm"},
+			"linux":   {"386", "amd64", "arm"},
+			"openbsd": {"386", "amd64"},
+			"solaris": {"amd64"},
+			"windows": {"386", "amd64"},
+		},
+	},
+	{
+		Name:       Terraform,
+		ReleaseURL: ReleaseURLBase,
+		Checkpoint: true,
+		Archive:    defaultArchive,
+		Platforms: map[string][]string{
+			"darwin":  {"amd64", "arm64"},
+			"freebsd": {"386", "amd64", "arm"},
+			"linux":   {"386", "amd64", "arm", "arm64"},
+			"openbsd": {"386", "amd64"},
+			"solaris": {"amd64"},
+			"windows": {"386", "amd64"},
+		},
+	},
+	{
+		Name:       TerraformLS,
+		ReleaseURL: ReleaseURLBase,
+		Checkpoint: false,
+		Archive:    defaultArchive,
+		Platforms: map[string][]string{
+			"darwin":  {"amd64", "arm64"},
+			"freebsd": {"386", "amd64", "arm"},
+			"linux":   {"386", "amd64", "arm", "arm64"},
+			"openbsd": {"386", "amd64"},
+			"windows": {"386", "amd64"},
+		},
+	},
+	{
+		Name:       Vagrant,
+		ReleaseURL: ReleaseURLBase,
+		Checkpoint: true,
+		Archive:    defaultArchive,
+		// Vagrant ships installers for macOS and Windows, which were named
+		// for the x86_64 and i686 architectures before 2.3.0
+		Archives: map[string]string{
+			"darwin":  `{{.Name}}_{{.Version}}_{{if atLeast .Version "2.3.0"}}{{.OS}}_{{.Arch}}{{else}}x86_64{{end}}.dmg`,
+			"windows": `{{.Name}}_{{.Version}}_{{if atLeast .Version "2.3.0"}}{{.OS}}_{{if eq .Arch "386"}}i686{{else}}{{.Arch}}{{end}}{{else}}{{if eq .Arch "386"}}i686{{else}}x86_64{{end}}{{end}}.msi`,
+		},
+		Platforms: map[string][]string{
+			"darwin":  {"amd64", "arm64"},
+			"linux":   {"amd64"},
+			"windows": {"386", "amd64"},
+		},
+	},
+	{
+		Name:       Vault,
+		ReleaseURL: ReleaseURLBase,
+		Checkpoint: false,
+		Archive:    defaultArchive,
+		Platforms: map[string][]string{
+			"darwin":  {"amd64", "arm64"},
+			"freebsd": {"386", "amd64", "arm"},
+			"linux":   {"386", "amd64", "arm", "arm64"},
+			"openbsd": {"386", "amd64"},
+			"solaris": {"amd64"},
+			"windows": {"386", "amd64"},
+		},
+	},
+}
+
+var (
+	registryMu sync.Mutex
+	registry   map[string]*BinarySpec
+)
+
+// loadRegistry returns the built in binaries merged with those described in
+// binaries.yaml in the hvm configuration directory; an entry there with the
+// name of a built in binary replaces it
+func loadRegistry() map[string]*BinarySpec {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if registry == nil {
+		registry = map[string]*BinarySpec{}
+		for i := range builtinBinaries {
+			if builtinBinaries[i].Checksums == "" {
+				builtinBinaries[i].Checksums = defaultChecksums
+			}
+			registry[builtinBinaries[i].Name] = &builtinBinaries[i]
+		}
+		specs, err := readBinariesFile()
+		if err != nil {
+			// Fall back to the built in binaries rather than refusing to run
+			warn("%v", err)
+			return registry
+		}
+		for i := range specs {
+			spec := specs[i]
+			if spec.Name == "" {
+				continue
+			}
+			if spec.ReleaseURL == "" {
+				spec.ReleaseURL = ReleaseURLBase
+			}
+			if spec.Archive == "" {
+				spec.Archive = defaultArchive
+			}
+			if spec.Checksums == "" {
+				spec.Checksums = defaultChecksums
+			}
+			if spec.Tag == "" {
+				spec.Tag = defaultTag
+			}
+			if _, err := spec.render("tag", spec.Tag, "", "", ""); err != nil {
+				warn("ignoring %s in binaries file: %v", spec.Name, err)
+				continue
+			}
+			registry[spec.Name] = &spec
+		}
+	}
+	return registry
+}
+
+// resetRegistry discards the loaded registry so that it is read again, such as
+// from the binaries.yaml of a newly configured directory
+func resetRegistry() {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = nil
+}
+
+// readBinariesFile reads the binary descriptions from binaries.yaml, if any
+func readBinariesFile() ([]BinarySpec, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	binariesFile := fmt.Sprintf("%s/binaries.yaml", dir)
+	if _, err := os.Stat(binariesFile); os.IsNotExist(err) {
+		return nil, nil
+	}
+	v := viper.New()
+	v.SetConfigFile(binariesFile)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("Cannot read binaries file %s with error: %v", binariesFile, err)
+	}
+	specs := []BinarySpec{}
+	if err := v.UnmarshalKey("binaries", &specs); err != nil {
+		return nil, fmt.Errorf("Cannot parse binaries file %s with error: %v", binariesFile, err)
+	}
+	return specs, nil
+}
+
+// LookupBinary returns the description of the named binary from the registry
+func LookupBinary(name string) (*BinarySpec, bool) {
+	spec, ok := loadRegistry()[name]
+	return spec, ok
+}
+
+// SupportedBinaries returns the names of the binaries hvm can install and use
+func SupportedBinaries() []string {
+	names := []string{}
+	for name := range loadRegistry() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsSupported returns true if hvm can install and use the named binary
+func IsSupported(name string) bool {
+	_, ok := LookupBinary(name)
+	return ok
+}
+
+// releaseURL returns the URL base of the releases site publishing binary
+func releaseURL(binary string) string {
+	if spec, ok := LookupBinary(binary); ok {
+		return spec.ReleaseURL
+	}
+	return ReleaseURLBase
+}